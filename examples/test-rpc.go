@@ -5,6 +5,7 @@ import (
 	"log"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/openshift-telco/go-netconf-client/netconf"
@@ -28,6 +29,10 @@ func testNotification() {
 	notificationSession := createSession(12346)
 
 	callback := func(event netconf.Event) {
+		if event.Err() != nil {
+			println("Notification stream terminated:", event.Err().Error())
+			return
+		}
 		reply := event.Notification()
 		println(reply.RawReply)
 	}
@@ -67,46 +72,38 @@ func testRPC() {
 }
 
 // Execute all types of RPC against the device
-// Add a 100ms delay after each RPC to leave enough time for the device to reply
-// Else, too many request and things get bad.
+// The session bounds the RPCs in flight, so the device is not flooded with requests.
 func execRPC(session *netconf.Session) {
 
 	// Get Config
 	g := message.NewGetConfig(message.DatastoreRunning, message.FilterTypeSubtree, "")
 	session.AsyncRPC(g, defaultLogRpcReplyCallback(g.MessageID))
-	time.Sleep(100 * time.Millisecond)
 
 	// Get
 	gt := message.NewGet("", "")
 	session.AsyncRPC(gt, defaultLogRpcReplyCallback(gt.MessageID))
-	time.Sleep(100 * time.Millisecond)
 
 	// Lock
 	l := message.NewLock(message.DatastoreCandidate)
 	session.AsyncRPC(l, defaultLogRpcReplyCallback(l.MessageID))
-	time.Sleep(100 * time.Millisecond)
 
 	// EditConfig
 	data := "<toaster xmlns=\"http://netconfcentral.org/ns/toaster\">\n    <darknessFactor>750</darknessFactor>\n</toaster>"
 	e := message.NewEditConfig(message.DatastoreCandidate, message.DefaultOperationTypeMerge, data)
 	session.AsyncRPC(e, defaultLogRpcReplyCallback(e.MessageID))
-	time.Sleep(100 * time.Millisecond)
 
 	// Commit
 	c := message.NewCommit()
 	session.AsyncRPC(c, defaultLogRpcReplyCallback(c.MessageID))
-	time.Sleep(100 * time.Millisecond)
 
 	// Unlock
 	u := message.NewUnlock(message.DatastoreCandidate)
 	session.AsyncRPC(u, defaultLogRpcReplyCallback(u.MessageID))
-	time.Sleep(100 * time.Millisecond)
 
 	// RPC
 	d := "    <make-toast xmlns=\"http://netconfcentral.org/ns/toaster\">\n        <toasterDoneness>9</toasterDoneness>\n        <toasterToastType>frozen-waffle</toasterToastType>\n     </make-toast>"
 	rpc := message.NewRPC(d)
 	session.AsyncRPC(rpc, defaultLogRpcReplyCallback(rpc.MessageID))
-	time.Sleep(100 * time.Millisecond)
 
 	// RPCs
 	rpc0 := message.NewGetConfig(message.DatastoreRunning, "", "")
@@ -131,19 +128,21 @@ func execRPC(session *netconf.Session) {
 
 func createSession(port int) *netconf.Session {
 	sshConfig := &ssh.ClientConfig{
-		User:            "admin",
-		Auth:            []ssh.AuthMethod{ssh.Password("admin")},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		User: "admin",
+		Auth: []ssh.AuthMethod{ssh.Password("admin")},
+		// Trust the host key on first use, and verify it afterwards
+		HostKeyCallback: netconf.TOFUHostKeyCallback(
+			netconf.NewFileHostKeyStore(filepath.Join(os.TempDir(), "netconf_known_hosts")),
+		),
 	}
 
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
-	s, err := netconf.NewSessionFromSSHConfig(fmt.Sprintf("127.0.0.1:%d", port), sshConfig, netconf.WithSessionLogger(logger))
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	capabilities := netconf.DefaultCapabilities
-	err = s.SendHello(&message.Hello{Capabilities: capabilities})
+	s, err := netconf.NewSessionFromSSHConfig(
+		fmt.Sprintf("127.0.0.1:%d", port), sshConfig,
+		netconf.WithSessionLogger(logger),
+		netconf.WithClientCapabilities(netconf.DefaultCapabilities...),
+		netconf.WithMaxInFlight(4),
+	)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -156,6 +155,7 @@ func defaultLogRpcReplyCallback(eventId string) netconf.Callback {
 		reply := event.RPCReply()
 		if reply == nil {
 			println("Failed to execute RPC")
+			return
 		}
 		if event.EventID() == eventId {
 			println("Successfully executed RPC")
@@ -2,6 +2,6 @@ module github.com/openshift-telco/go-netconf-client
 
 go 1.22.2
 
-require golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
+require golang.org/x/crypto v0.31.0
 
-require golang.org/x/sys v0.0.0-20210809222454-d867a43fc93e // indirect
+require golang.org/x/sys v0.28.0 // indirect
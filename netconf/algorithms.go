@@ -0,0 +1,167 @@
+package netconf
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHAlgorithmProfile is a named set of SSH algorithms, in preference order.
+type SSHAlgorithmProfile struct {
+	Name              string
+	KeyExchanges      []string
+	Ciphers           []string
+	MACs              []string
+	HostKeyAlgorithms []string
+}
+
+var (
+	// SSHAlgorithmsModern only allows algorithms considered secure.
+	SSHAlgorithmsModern = SSHAlgorithmProfile{
+		Name: "modern",
+		KeyExchanges: []string{
+			"curve25519-sha256@libssh.org",
+			"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+			"diffie-hellman-group14-sha256",
+		},
+		Ciphers: []string{
+			"aes128-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+			"aes128-ctr", "aes192-ctr", "aes256-ctr",
+		},
+		MACs: []string{
+			"hmac-sha2-256-etm@openssh.com", "hmac-sha2-256",
+		},
+		HostKeyAlgorithms: []string{
+			ssh.CertAlgoED25519v01,
+			ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01,
+			ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01,
+			ssh.KeyAlgoED25519,
+			ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+			ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256,
+		},
+	}
+
+	// SSHAlgorithmsCompatible extends SSHAlgorithmsModern with the SHA-1 based algorithms still widely deployed,
+	// such as ssh-rsa host keys.
+	SSHAlgorithmsCompatible = SSHAlgorithmProfile{
+		Name: "compatible",
+		KeyExchanges: extend(SSHAlgorithmsModern.KeyExchanges,
+			"diffie-hellman-group14-sha1",
+			"diffie-hellman-group-exchange-sha256",
+		),
+		Ciphers: SSHAlgorithmsModern.Ciphers,
+		MACs: extend(SSHAlgorithmsModern.MACs,
+			"hmac-sha1",
+		),
+		HostKeyAlgorithms: extend(SSHAlgorithmsModern.HostKeyAlgorithms,
+			ssh.CertAlgoRSAv01,
+			ssh.KeyAlgoRSA,
+		),
+	}
+
+	// SSHAlgorithmsLegacy extends SSHAlgorithmsCompatible with the weak algorithms of old network gear,
+	// such as diffie-hellman-group1-sha1, aes128-cbc or ssh-dss host keys. Use it only for devices which
+	// do not support anything else.
+	SSHAlgorithmsLegacy = SSHAlgorithmProfile{
+		Name: "legacy",
+		KeyExchanges: extend(SSHAlgorithmsCompatible.KeyExchanges,
+			"diffie-hellman-group-exchange-sha1",
+			"diffie-hellman-group1-sha1",
+		),
+		Ciphers: extend(SSHAlgorithmsCompatible.Ciphers,
+			"aes128-cbc",
+			"3des-cbc",
+		),
+		MACs: extend(SSHAlgorithmsCompatible.MACs,
+			"hmac-sha1-96",
+		),
+		HostKeyAlgorithms: extend(SSHAlgorithmsCompatible.HostKeyAlgorithms,
+			ssh.CertAlgoDSAv01,
+			ssh.KeyAlgoDSA,
+		),
+	}
+)
+
+// extend returns a new slice holding base followed by more.
+func extend(base []string, more ...string) []string {
+	return append(append([]string(nil), base...), more...)
+}
+
+// SSHAlgorithmProfileByName returns the profile with the provided name: modern, compatible or legacy.
+func SSHAlgorithmProfileByName(name string) (SSHAlgorithmProfile, error) {
+	for _, p := range []SSHAlgorithmProfile{SSHAlgorithmsModern, SSHAlgorithmsCompatible, SSHAlgorithmsLegacy} {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return SSHAlgorithmProfile{}, fmt.Errorf("ssh: unknown algorithm profile %q", name)
+}
+
+// Apply configures config to use the algorithms of the profile, and returns it.
+func (p SSHAlgorithmProfile) Apply(config *ssh.ClientConfig) *ssh.ClientConfig {
+	config.KeyExchanges = append([]string(nil), p.KeyExchanges...)
+	config.Ciphers = append([]string(nil), p.Ciphers...)
+	config.MACs = append([]string(nil), p.MACs...)
+	config.HostKeyAlgorithms = append([]string(nil), p.HostKeyAlgorithms...)
+	return config
+}
+
+// ErrAlgorithmMismatch indicates the client and the server do not support any common SSH algorithm.
+var ErrAlgorithmMismatch = errors.New("ssh: no common algorithm")
+
+// AlgorithmMismatchError reports the algorithms offered by each side when the SSH handshake failed
+// because they have none in common. It wraps ErrAlgorithmMismatch.
+type AlgorithmMismatchError struct {
+	// What is the kind of algorithm negotiated, e.g. "key exchange", "host key" or "client to server cipher".
+	What   string
+	Client []string
+	Server []string
+}
+
+// Error generates a string representation of the negotiation failure
+func (e *AlgorithmMismatchError) Error() string {
+	return fmt.Sprintf("%s for %s; client offered: %v, server offered: %v", ErrAlgorithmMismatch, e.What, e.Client, e.Server)
+}
+
+// Unwrap returns ErrAlgorithmMismatch, so errors.Is can be used to check for negotiation failures.
+func (e *AlgorithmMismatchError) Unwrap() error {
+	return ErrAlgorithmMismatch
+}
+
+// algorithmMismatchRegex matches the negotiation failure reported by the ssh package, which is not typed.
+var algorithmMismatchRegex = regexp.MustCompile(`ssh: no common algorithm for ([^;]+); client offered: \[([^\]]*)\], server offered: \[([^\]]*)\]`)
+
+// sshHandshakeError turns the algorithm negotiation failures of the ssh package into an AlgorithmMismatchError.
+// Other errors are returned as-is.
+func sshHandshakeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	m := algorithmMismatchRegex.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	return &AlgorithmMismatchError{What: m[1], Client: negotiated(m[2]), Server: negotiated(m[3])}
+}
+
+// pseudoAlgorithms are advertised among the key exchanges to signal extensions, and are not actual algorithms.
+var pseudoAlgorithms = map[string]bool{
+	"ext-info-c":                   true,
+	"ext-info-s":                   true,
+	"kex-strict-c-v00@openssh.com": true,
+	"kex-strict-s-v00@openssh.com": true,
+}
+
+// negotiated returns the algorithms of a list offered during the negotiation, without the pseudo-algorithms.
+func negotiated(offered string) []string {
+	var algorithms []string
+	for _, algorithm := range strings.Fields(offered) {
+		if !pseudoAlgorithms[algorithm] {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	return algorithms
+}
@@ -0,0 +1,257 @@
+package netconf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ErrPassphraseRequired indicates a private key is encrypted, but no passphrase was provided.
+var ErrPassphraseRequired = errors.New("ssh: private key is encrypted and requires a passphrase")
+
+// KeyboardInteractivePrompt answers a single question asked by the server during keyboard-interactive
+// authentication. name and instruction are provided by the server to describe the challenge, and echo
+// reports whether the answer may be displayed.
+type KeyboardInteractivePrompt func(name, instruction, question string, echo bool) (string, error)
+
+// SSHAuthOption adds a source of credentials to the ssh.ClientConfig built by NewSSHClientConfig.
+type SSHAuthOption func(*sshAuth) error
+
+// sshAuth accumulates the sources of credentials, in order.
+type sshAuth struct {
+	methods []ssh.AuthMethod
+	// signers holds the public key sources, all tried within a single publickey method
+	signers []func() ([]ssh.Signer, error)
+}
+
+// publicKeys adds a public key source. As the ssh package only tries every authentication method once,
+// all the public key sources are combined in a single method, at the position of the first one. A source
+// failing, e.g. an unreachable agent, is skipped: returning its error would abort the authentication. The
+// errors are only returned when no source yields a signer, and no other method is left to try.
+func (a *sshAuth) publicKeys(signers func() ([]ssh.Signer, error)) {
+	if len(a.signers) == 0 {
+		a.methods = append(a.methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			var all []ssh.Signer
+			var errs []error
+			for _, source := range a.signers {
+				s, err := source()
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				all = append(all, s...)
+			}
+			if len(all) == 0 && len(a.methods) == 1 {
+				return nil, errors.Join(errs...)
+			}
+			return all, nil
+		}))
+	}
+	a.signers = append(a.signers, signers)
+}
+
+// NewSSHClientConfig builds an ssh.ClientConfig for user, authenticating with the provided sources of
+// credentials, tried in order: for instance a private key, then the keys of the ssh-agent, then a password.
+// Note that all the public keys are tried before moving on to the next method.
+func NewSSHClientConfig(user string, hostKeyCallback ssh.HostKeyCallback, options ...SSHAuthOption) (*ssh.ClientConfig, error) {
+	auth := &sshAuth{}
+	for _, opt := range options {
+		if err := opt(auth); err != nil {
+			return nil, err
+		}
+	}
+	return &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth.methods,
+		HostKeyCallback: hostKeyCallback,
+	}, nil
+}
+
+// WithPrivateKey authenticates using the provided PEM encoded private key, in either OpenSSH, PKCS#1, PKCS#8
+// or SEC1 format. passphrase is used when the key is encrypted.
+func WithPrivateKey(pemBytes []byte, passphrase string) SSHAuthOption {
+	return func(a *sshAuth) error {
+		signer, err := ParsePrivateKey(pemBytes, passphrase)
+		if err != nil {
+			return err
+		}
+		a.publicKeys(staticSigners(signer))
+		return nil
+	}
+}
+
+// WithPrivateKeyFile authenticates using the private key held in file. See WithPrivateKey.
+func WithPrivateKeyFile(file string, passphrase string) SSHAuthOption {
+	return func(a *sshAuth) error {
+		pemBytes, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		return WithPrivateKey(pemBytes, passphrase)(a)
+	}
+}
+
+// WithCertificateFile authenticates using an SSH user certificate, held in certFile in the OpenSSH format
+// (e.g. id_ed25519-cert.pub), along with its private key held in keyFile. See WithPrivateKey.
+func WithCertificateFile(keyFile string, certFile string, passphrase string) SSHAuthOption {
+	return func(a *sshAuth) error {
+		pemBytes, err := os.ReadFile(keyFile)
+		if err != nil {
+			return err
+		}
+		signer, err := ParsePrivateKey(pemBytes, passphrase)
+		if err != nil {
+			return err
+		}
+		certBytes, err := os.ReadFile(certFile)
+		if err != nil {
+			return err
+		}
+		pub, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
+		if err != nil {
+			return err
+		}
+		cert, ok := pub.(*ssh.Certificate)
+		if !ok {
+			return fmt.Errorf("ssh: %s does not hold a certificate", certFile)
+		}
+		certSigner, err := ssh.NewCertSigner(cert, signer)
+		if err != nil {
+			return err
+		}
+		a.publicKeys(staticSigners(certSigner))
+		return nil
+	}
+}
+
+// WithSSHAgent authenticates using the keys and certificates of the ssh-agent listening on the UNIX socket
+// referenced by the SSH_AUTH_SOCK environment variable. The agent is connected to only when authenticating.
+func WithSSHAgent() SSHAuthOption {
+	return func(a *sshAuth) error {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return errors.New("ssh: SSH_AUTH_SOCK is not set")
+		}
+		return WithSSHAgentSocket(socket)(a)
+	}
+}
+
+// WithSSHAgentSocket authenticates using the keys and certificates of the ssh-agent listening on the UNIX socket.
+func WithSSHAgentSocket(socket string) SSHAuthOption {
+	return func(a *sshAuth) error {
+		a.publicKeys(func() ([]ssh.Signer, error) {
+			return agentSigners(socket)
+		})
+		return nil
+	}
+}
+
+// WithPassword authenticates using a password.
+func WithPassword(password string) SSHAuthOption {
+	return func(a *sshAuth) error {
+		a.methods = append(a.methods, ssh.Password(password))
+		return nil
+	}
+}
+
+// WithKeyboardInteractive authenticates using keyboard-interactive, as defined in RFC4256, invoking prompt
+// for every question asked by the server.
+func WithKeyboardInteractive(prompt KeyboardInteractivePrompt) SSHAuthOption {
+	return func(a *sshAuth) error {
+		a.methods = append(a.methods, ssh.KeyboardInteractive(
+			func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i, question := range questions {
+					answer, err := prompt(name, instruction, question, echos[i])
+					if err != nil {
+						return nil, err
+					}
+					answers[i] = answer
+				}
+				return answers, nil
+			},
+		))
+		return nil
+	}
+}
+
+// ParsePrivateKey parses a PEM encoded private key, in either OpenSSH, PKCS#1, PKCS#8 or SEC1 format,
+// decrypting it with passphrase if needed. Data following the key, if any, is ignored.
+func ParsePrivateKey(pemBytes []byte, passphrase string) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey(pemBytes)
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return signer, err
+	}
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	return ssh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(passphrase))
+}
+
+func staticSigners(signers ...ssh.Signer) func() ([]ssh.Signer, error) {
+	return func() ([]ssh.Signer, error) {
+		return signers, nil
+	}
+}
+
+// agentSigners lists the keys of the agent. Every signature uses its own connection to the agent,
+// so no connection outlives the authentication.
+func agentSigners(socket string) ([]ssh.Signer, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: failed to connect to agent: %w", err)
+	}
+	defer conn.Close()
+
+	keys, err := agent.NewClient(conn).List()
+	if err != nil {
+		return nil, err
+	}
+	signers := make([]ssh.Signer, 0, len(keys))
+	for _, key := range keys {
+		pub, err := ssh.ParsePublicKey(key.Blob)
+		if err != nil {
+			continue
+		}
+		signers = append(signers, &agentSigner{socket: socket, pub: pub})
+	}
+	return signers, nil
+}
+
+// agentSigner signs using a key held by the agent.
+type agentSigner struct {
+	socket string
+	pub    ssh.PublicKey
+}
+
+func (s *agentSigner) PublicKey() ssh.PublicKey {
+	return s.pub
+}
+
+func (s *agentSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	return s.SignWithAlgorithm(rand, data, "")
+}
+
+// SignWithAlgorithm lets RSA keys sign using rsa-sha2-256 and rsa-sha2-512, rather than only ssh-rsa.
+func (s *agentSigner) SignWithAlgorithm(_ io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	var flags agent.SignatureFlags
+	switch algorithm {
+	case ssh.KeyAlgoRSASHA256:
+		flags = agent.SignatureFlagRsaSha256
+	case ssh.KeyAlgoRSASHA512:
+		flags = agent.SignatureFlagRsaSha512
+	}
+
+	conn, err := net.Dial("unix", s.socket)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: failed to connect to agent: %w", err)
+	}
+	defer conn.Close()
+	return agent.NewClient(conn).SignWithFlags(s.pub, data, flags)
+}
@@ -0,0 +1,357 @@
+package netconf
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// callHomeSSHDefaultPort is the port devices connect to when calling home using SSH
+	// https://datatracker.ietf.org/doc/html/rfc8071#section-6
+	callHomeSSHDefaultPort = 4334
+	// callHomeDefaultHandshakeTimeout bounds the transport handshake and hello exchange of a device calling home.
+	callHomeDefaultHandshakeTimeout = 30 * time.Second
+)
+
+var (
+	// ErrCallHomeListenerClosed is returned by CallHomeListener.Serve after the listener has been closed.
+	ErrCallHomeListenerClosed = errors.New("netconf: call home listener closed")
+	// ErrCallHomeListenerServing is returned by CallHomeListener.Serve when it was already called.
+	ErrCallHomeListenerServing = errors.New("netconf: call home listener already serving")
+)
+
+// CallHomeDevice identifies the NETCONF server that called home.
+type CallHomeDevice struct {
+	// RemoteAddr is the address the device connected from.
+	RemoteAddr net.Addr
+	// HostKey is the SSH host key presented by the device, when calling home using SSH.
+	HostKey ssh.PublicKey
+	// Certificate is the certificate presented by the device, when calling home using TLS.
+	// Its subject alternative names can be used to identify the device.
+	Certificate *x509.Certificate
+	// Fingerprint is the tls-fingerprint of Certificate, see CertificateFingerprint.
+	Fingerprint string
+}
+
+// CallHomeSession is a NETCONF session established with a device calling home.
+type CallHomeSession struct {
+	*Session
+	Device *CallHomeDevice
+}
+
+// CallHomeHandler is invoked with a ready session every time a device calls home.
+// The handler owns the session and is responsible for closing it.
+type CallHomeHandler func(*CallHomeSession)
+
+// CallHomeSSHConfigFunc returns the ssh client configuration to use with the device calling home from remote.
+// Returning an error, or a nil configuration, rejects the connection.
+type CallHomeSSHConfigFunc func(remote net.Addr) (*ssh.ClientConfig, error)
+
+// CallHomeOption allow optional configuration for the call home listener.
+type CallHomeOption func(*CallHomeListener)
+
+// WithCallHomeSessionOptions sets the options used to create every session accepted by the listener.
+func WithCallHomeSessionOptions(options ...SessionOption) CallHomeOption {
+	return func(l *CallHomeListener) {
+		l.sessionOptions = options
+	}
+}
+
+// WithCallHomeLogger set the listener logger provided in the call home option.
+func WithCallHomeLogger(logger Logger) CallHomeOption {
+	return func(l *CallHomeListener) {
+		l.logger = logger
+	}
+}
+
+// WithCallHomeHandshakeTimeout bounds the time a device calling home has to complete the transport handshake
+// and the hello exchange. Defaults to 30 seconds.
+func WithCallHomeHandshakeTimeout(timeout time.Duration) CallHomeOption {
+	return func(l *CallHomeListener) {
+		l.handshakeTimeout = timeout
+	}
+}
+
+// CallHomeListener accepts NETCONF call home connections, as defined in RFC8071, and turns them into sessions.
+type CallHomeListener struct {
+	listener         net.Listener
+	establish        func(conn net.Conn) (Transport, *CallHomeDevice, error)
+	sessionOptions   []SessionOption
+	logger           Logger
+	handshakeTimeout time.Duration
+	// serving is set once Serve was called
+	serving atomic.Bool
+
+	mu       sync.Mutex
+	pending  map[net.Conn]struct{}
+	wg       sync.WaitGroup
+	done     chan struct{}
+	sessions chan *CallHomeSession
+}
+
+// ListenCallHomeSSH listens on address for devices calling home using SSH.
+//
+// address can be a host (e.g. 0.0.0.0), which utilizes the default NETCONF call home
+// over SSH port of 4334, or specify a port with the following format <host>:<port>.
+//
+// configFor is invoked for every accepted connection to look up the ssh.ClientConfig of the device,
+// for which the listener acts as SSH client as mandated by RFC8071.
+func ListenCallHomeSSH(address string, configFor CallHomeSSHConfigFunc, options ...CallHomeOption) (*CallHomeListener, error) {
+	if !strings.Contains(address, ":") {
+		address = fmt.Sprintf("%s:%d", address, callHomeSSHDefaultPort)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return NewCallHomeSSHListener(listener, configFor, options...), nil
+}
+
+// NewCallHomeSSHListener accepts devices calling home using SSH on the provided listener.
+// See ListenCallHomeSSH for arguments.
+func NewCallHomeSSHListener(listener net.Listener, configFor CallHomeSSHConfigFunc, options ...CallHomeOption) *CallHomeListener {
+	establish := func(conn net.Conn) (Transport, *CallHomeDevice, error) {
+		device := &CallHomeDevice{RemoteAddr: conn.RemoteAddr()}
+
+		config, err := configFor(conn.RemoteAddr())
+		if err != nil {
+			return nil, device, err
+		}
+		if config == nil {
+			return nil, device, errors.New("ssh: no client configuration for the device")
+		}
+		if config.HostKeyCallback == nil {
+			return nil, device, fmt.Errorf("ssh: must specify HostKeyCallback")
+		}
+
+		// Record the host key presented by the device so it can be identified.
+		deviceConfig := *config
+		deviceConfig.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			device.HostKey = key
+			return config.HostKeyCallback(hostname, remote, key)
+		}
+
+		t, err := connToTransport(conn, &deviceConfig)
+		if err != nil {
+			return nil, device, err
+		}
+		return t, device, nil
+	}
+	return newCallHomeListener(listener, establish, options...)
+}
+
+func newCallHomeListener(
+	listener net.Listener, establish func(net.Conn) (Transport, *CallHomeDevice, error), options ...CallHomeOption,
+) *CallHomeListener {
+	l := &CallHomeListener{
+		listener:         listener,
+		establish:        establish,
+		handshakeTimeout: callHomeDefaultHandshakeTimeout,
+		pending:          make(map[net.Conn]struct{}),
+		done:             make(chan struct{}),
+		sessions:         make(chan *CallHomeSession),
+	}
+	for _, opt := range options {
+		opt(l)
+	}
+
+	if l.logger == nil {
+		l.logger = slog.New(slog.NewJSONHandler(io.Discard, nil))
+	}
+	return l
+}
+
+// Addr returns the address the listener is accepting devices on.
+func (l *CallHomeListener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// Sessions returns the channel sessions are delivered to when Serve is called with a nil handler.
+func (l *CallHomeListener) Sessions() <-chan *CallHomeSession {
+	return l.sessions
+}
+
+// Serve accepts devices calling home until the listener is closed, and invokes handler with every
+// session successfully established. Each device is handled in its own goroutine, so a slow device
+// does not prevent others from calling home. When handler is nil, sessions are delivered on the
+// channel returned by Sessions instead, which is closed when Serve returns.
+//
+// Serve always returns a non-nil error. After Close or Shutdown, the returned error is ErrCallHomeListenerClosed.
+// Serve can only be called once: later calls return ErrCallHomeListenerServing.
+func (l *CallHomeListener) Serve(handler CallHomeHandler) error {
+	if !l.serving.CompareAndSwap(false, true) {
+		return ErrCallHomeListenerServing
+	}
+	if handler == nil {
+		defer close(l.sessions)
+	}
+
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			if !l.isClosed() {
+				// Unexpected failure: abort everything in progress so no goroutine outlives Serve.
+				_ = l.Close()
+			} else {
+				err = ErrCallHomeListenerClosed
+			}
+			l.wg.Wait()
+			return err
+		}
+
+		if !l.track(conn) {
+			_ = conn.Close()
+			continue
+		}
+		go func() {
+			s := l.accept(conn)
+			if s != nil && handler == nil {
+				l.deliver(s)
+			}
+			l.wg.Done()
+			if s != nil && handler != nil {
+				handler(s)
+			}
+		}()
+	}
+}
+
+// accept establishes the session with the device behind conn, or returns nil if it failed to do so.
+func (l *CallHomeListener) accept(conn net.Conn) *CallHomeSession {
+	s, err := l.handshake(conn)
+	l.untrack(conn)
+	if err != nil {
+		l.logger.Warn("failed to establish call home session",
+			"remoteAddr", conn.RemoteAddr().String(),
+			"err", err,
+		)
+		_ = conn.Close()
+		return nil
+	}
+	return s
+}
+
+// deliver sends the session on the Sessions channel, or closes it if the listener is closed before
+// anybody receives it.
+func (l *CallHomeListener) deliver(s *CallHomeSession) {
+	select {
+	case l.sessions <- s:
+	case <-l.done:
+		_ = s.Close()
+	}
+}
+
+// handshake establishes the transport over the accepted connection and exchanges hello messages.
+func (l *CallHomeListener) handshake(conn net.Conn) (*CallHomeSession, error) {
+	if l.handshakeTimeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(l.handshakeTimeout))
+	}
+
+	t, device, err := l.establish(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := NewSession(t, l.sessionOptions...)
+	if err != nil {
+		_ = t.Close()
+		return nil, err
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	l.logger.Info("device called home",
+		"remoteAddr", device.RemoteAddr.String(),
+		"sessionID", s.SessionID,
+	)
+	return &CallHomeSession{Session: s, Device: device}, nil
+}
+
+func (l *CallHomeListener) track(conn net.Conn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.isClosed() {
+		return false
+	}
+	l.pending[conn] = struct{}{}
+	l.wg.Add(1)
+	return true
+}
+
+func (l *CallHomeListener) untrack(conn net.Conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.pending, conn)
+}
+
+func (l *CallHomeListener) isClosed() bool {
+	select {
+	case <-l.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop prevents new devices from being accepted.
+func (l *CallHomeListener) stop() error {
+	l.mu.Lock()
+	if l.isClosed() {
+		l.mu.Unlock()
+		return nil
+	}
+	close(l.done)
+	l.mu.Unlock()
+	return l.listener.Close()
+}
+
+// abort closes the connections of the devices still in the middle of their handshake.
+func (l *CallHomeListener) abort() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for conn := range l.pending {
+		_ = conn.Close()
+	}
+}
+
+// Close immediately stops accepting devices and aborts the handshakes in progress.
+// Sessions already handed to the handler are not affected.
+func (l *CallHomeListener) Close() error {
+	err := l.stop()
+	l.abort()
+	return err
+}
+
+// Shutdown gracefully stops the listener: it stops accepting devices and waits for the handshakes
+// in progress to complete. If ctx expires first, the remaining handshakes are aborted and the
+// context error is returned. Sessions completing after Shutdown is called are handed to the handler,
+// or closed when the Sessions channel is used, as nobody is expected to receive them anymore.
+func (l *CallHomeListener) Shutdown(ctx context.Context) error {
+	err := l.stop()
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		l.abort()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,109 @@
+package netconf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// callHomeTLSDefaultPort is the port devices connect to when calling home using TLS
+	// https://datatracker.ietf.org/doc/html/rfc8071#section-6
+	callHomeTLSDefaultPort = 4335
+)
+
+// CallHomeTLSConfigFunc returns the tls client configuration to use with the device calling home from remote.
+// Returning an error, or a nil configuration, rejects the connection.
+type CallHomeTLSConfigFunc func(remote net.Addr) (*tls.Config, error)
+
+// ListenCallHomeTLS listens on address for devices calling home using TLS.
+//
+// address can be a host (e.g. 0.0.0.0), which utilizes the default NETCONF call home
+// over TLS port of 4335, or specify a port with the following format <host>:<port>.
+//
+// configFor is invoked for every accepted connection to look up the tls.Config of the device, for which
+// the listener acts as TLS client as mandated by RFC8071. The device certificate is verified against
+// the RootCAs of the configuration. As the name of a device calling home is not known in advance, the
+// certificate is not matched against a host name unless ServerName is set; use VerifyCertToName, or the
+// Device attached to the session, to map the certificate to a device.
+func ListenCallHomeTLS(address string, configFor CallHomeTLSConfigFunc, options ...CallHomeOption) (*CallHomeListener, error) {
+	if !strings.Contains(address, ":") {
+		address = fmt.Sprintf("%s:%d", address, callHomeTLSDefaultPort)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return NewCallHomeTLSListener(listener, configFor, options...), nil
+}
+
+// NewCallHomeTLSListener accepts devices calling home using TLS on the provided listener.
+// See ListenCallHomeTLS for arguments.
+func NewCallHomeTLSListener(listener net.Listener, configFor CallHomeTLSConfigFunc, options ...CallHomeOption) *CallHomeListener {
+	establish := func(conn net.Conn) (Transport, *CallHomeDevice, error) {
+		device := &CallHomeDevice{RemoteAddr: conn.RemoteAddr()}
+
+		config, err := configFor(conn.RemoteAddr())
+		if err != nil {
+			return nil, device, err
+		}
+		if config == nil {
+			return nil, device, errors.New("tls: no client configuration for the device")
+		}
+
+		tlsConn := tls.Client(conn, callHomeTLSConfig(config))
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, device, err
+		}
+
+		state := tlsConn.ConnectionState()
+		if len(state.PeerCertificates) == 0 {
+			return nil, device, errors.New("tls: device did not present a certificate")
+		}
+		device.Certificate = state.PeerCertificates[0]
+		device.Fingerprint = CertificateFingerprint(device.Certificate)
+
+		t := new(TransportTLS)
+		t.setupConn(tlsConn)
+		return t, device, nil
+	}
+	return newCallHomeListener(listener, establish, options...)
+}
+
+// callHomeTLSConfig returns a copy of config verifying the device certificate chain without requiring
+// a server name, unless one was explicitly configured.
+func callHomeTLSConfig(config *tls.Config) *tls.Config {
+	config = config.Clone()
+	if config.ServerName != "" || config.InsecureSkipVerify {
+		return config
+	}
+
+	verifyConnection := config.VerifyConnection
+	config.InsecureSkipVerify = true
+	config.VerifyConnection = func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return errors.New("tls: device did not present a certificate")
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range state.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+			Roots:         config.RootCAs,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		if err != nil {
+			return err
+		}
+		if verifyConnection != nil {
+			return verifyConnection(state)
+		}
+		return nil
+	}
+	return config
+}
@@ -0,0 +1,30 @@
+package netconf
+
+import "github.com/openshift-telco/go-netconf-client/netconf/message"
+
+// WithCapabilityCheck makes the session reject the operations requiring a capability the server did not
+// advertise, such as commit without `:candidate`, with a *message.CapabilityError rather than sending them.
+// Only the operations implementing message.CapabilityRequirer are checked.
+func WithCapabilityCheck() SessionOption {
+	return func(s *Session) {
+		s.capabilityCheck = true
+	}
+}
+
+// ServerCapabilities returns the capabilities advertised by the server in its hello message, parsed into
+// NETCONF capabilities and YANG modules.
+func (session *Session) ServerCapabilities() *message.Capabilities {
+	if session.serverCapabilities == nil {
+		return message.ParseCapabilities(session.Capabilities)
+	}
+	return session.serverCapabilities
+}
+
+// checkCapabilities returns a *message.CapabilityError when the capability check is enabled, and the operation
+// requires a capability the server did not advertise.
+func (session *Session) checkCapabilities(operation message.RPCMethod) error {
+	if !session.capabilityCheck {
+		return nil
+	}
+	return session.ServerCapabilities().Check(operation)
+}
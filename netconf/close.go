@@ -0,0 +1,141 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+)
+
+const (
+	// defaultCloseTimeout bounds the wait for the reply to close-session, and to kill-session.
+	defaultCloseTimeout = 10 * time.Second
+)
+
+// CloseOutcome reports how a session was closed by GracefulClose.
+type CloseOutcome int
+
+const (
+	// CloseAcknowledged indicates the server acknowledged close-session.
+	CloseAcknowledged CloseOutcome = iota
+	// CloseKilled indicates the session was terminated by kill-session, sent from another session.
+	CloseKilled
+	// CloseUnacknowledged indicates the transport was closed without the server acknowledging the end of the session.
+	CloseUnacknowledged
+	// CloseAlreadyClosed indicates the session was already closed.
+	CloseAlreadyClosed
+)
+
+// Names of close outcomes
+var closeOutcomeStrings = [...]string{
+	"acknowledged", "killed", "unacknowledged", "already-closed",
+}
+
+// String returns the name of the close outcome
+func (o CloseOutcome) String() string {
+	if o < 0 || int(o) >= len(closeOutcomeStrings) {
+		return fmt.Sprintf("CloseOutcome(%d)", o)
+	}
+	return closeOutcomeStrings[o]
+}
+
+// CloseOption allows optional configuration of GracefulClose.
+type CloseOption func(*closeOptions)
+
+type closeOptions struct {
+	timeout time.Duration
+	killer  *Session
+	force   bool
+}
+
+// WithCloseTimeout bounds the wait for the reply to close-session, and to kill-session. Defaults to 10 seconds.
+func WithCloseTimeout(timeout time.Duration) CloseOption {
+	return func(o *closeOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithKillFallback terminates the session with kill-session, sent from killer, when it does not acknowledge
+// close-session, e.g. to release the locks it holds.
+func WithKillFallback(killer *Session) CloseOption {
+	return func(o *closeOptions) {
+		o.killer = killer
+	}
+}
+
+// WithForceKill terminates a wedged session with kill-session, sent from killer, without sending close-session.
+func WithForceKill(killer *Session) CloseOption {
+	return func(o *closeOptions) {
+		o.killer = killer
+		o.force = true
+	}
+}
+
+// GracefulClose ends the session by sending close-session and waiting for the server to acknowledge it, within
+// the deadline of ctx and the close timeout. The transport is closed in every case, and the pending RPCs fail
+// with a SessionClosedError.
+//
+// The outcome reports how the session was ended. Unless it is CloseAcknowledged or CloseAlreadyClosed, the
+// error reports why close-session was not acknowledged.
+// https://datatracker.ietf.org/doc/html/rfc6241#section-7.8
+func (session *Session) GracefulClose(ctx context.Context, options ...CloseOption) (CloseOutcome, error) {
+	o := &closeOptions{timeout: defaultCloseTimeout}
+	for _, opt := range options {
+		opt(o)
+	}
+	if session.Closed() {
+		return CloseAlreadyClosed, nil
+	}
+	// Once close-session is sent, the server is expected to hang up: this is not a failure.
+	session.closing.Store(true)
+
+	var closeErr error
+	if !o.force {
+		closeErr = session.closeSession(ctx, o.timeout)
+		if closeErr == nil {
+			return CloseAcknowledged, session.Close()
+		}
+	}
+
+	if o.killer == nil {
+		return CloseUnacknowledged, errors.Join(closeErr, session.Close())
+	}
+	// ctx may have expired waiting for close-session: kill-session gets its own timeout.
+	killCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), o.timeout)
+	defer cancel()
+	if err := o.killer.KillSession(killCtx, session.SessionID); err != nil {
+		return CloseUnacknowledged, errors.Join(closeErr, err, session.Close())
+	}
+	return CloseKilled, session.Close()
+}
+
+// closeSession sends close-session, and waits for its reply. As for kill-session, the RPC does not wait for a
+// slot when the RPCs in flight are bounded, see WithMaxInFlight: a session is closed even when its window is full.
+func (session *Session) closeSession(ctx context.Context, timeout time.Duration) error {
+	reply, err := session.syncRPC(ctx, message.NewCloseSession(), false, []CallOption{WithCallTimeout(timeout)})
+	if err != nil {
+		return fmt.Errorf("close-session: %w", err)
+	}
+	if len(reply.Errors) != 0 {
+		return fmt.Errorf("close-session: failed with errors: %s", reply.Errors)
+	}
+	return nil
+}
+
+// KillSession forces the termination of the NETCONF session sessionID, by sending kill-session on this session.
+// The server aborts the operations in progress on the killed session, and releases its locks. kill-session
+// is sent even when the maximum number of RPCs in flight on this session is reached, see WithMaxInFlight.
+// https://datatracker.ietf.org/doc/html/rfc6241#section-7.9
+func (session *Session) KillSession(ctx context.Context, sessionID int, options ...CallOption) error {
+	reply, err := session.syncRPC(ctx, message.NewKillSession(strconv.Itoa(sessionID)), false, options)
+	if err != nil {
+		return fmt.Errorf("kill-session %d: %w", sessionID, err)
+	}
+	if len(reply.Errors) != 0 {
+		return fmt.Errorf("kill-session %d: failed with errors: %s", sessionID, reply.Errors)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package netconf
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Dialer establishes the network connections used to reach NETCONF servers. It is satisfied by
+// *net.Dialer, *ssh.Client, SOCKS5Dialer and HTTPConnectDialer, so they can be chained.
+type Dialer interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
+// ErrProxy indicates a proxy refused or failed to establish the requested connection.
+var ErrProxy = errors.New("proxy: connection failed")
+
+// SOCKS5Dialer dials through a SOCKS5 proxy, as defined in RFC1928, optionally authenticating
+// with a username and password as defined in RFC1929.
+type SOCKS5Dialer struct {
+	// Address of the proxy, with the format <host>:<port>.
+	Address string
+	// Username and Password are used when set.
+	Username string
+	Password string
+	// Forward is used to connect to the proxy. Defaults to a net.Dialer.
+	Forward Dialer
+}
+
+// Dial connects to address through the proxy. Only TCP networks are supported.
+func (d *SOCKS5Dialer) Dial(network, address string) (net.Conn, error) {
+	host, port, err := splitHostPort(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if len(host) > 255 {
+		return nil, fmt.Errorf("%w: host name too long", ErrProxy)
+	}
+
+	conn, err := forward(d.Forward).Dial("tcp", d.Address)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.connect(conn, host, port); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (d *SOCKS5Dialer) connect(conn net.Conn, host string, port uint16) error {
+	const (
+		version          = 5
+		noAuth           = 0
+		userPassAuth     = 2
+		noAcceptable     = 0xff
+		connectCommand   = 1
+		ipv4Address      = 1
+		domainAddress    = 3
+		ipv6Address      = 4
+		userPassVersion  = 1
+		succeeded        = 0
+		maxCredentialLen = 255
+	)
+
+	// Method negotiation
+	methods := []byte{noAuth}
+	if d.Username != "" || d.Password != "" {
+		if len(d.Username) > maxCredentialLen || len(d.Password) > maxCredentialLen {
+			return fmt.Errorf("%w: credentials too long", ErrProxy)
+		}
+		methods = []byte{userPassAuth}
+	}
+	if _, err := conn.Write(append([]byte{version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != version {
+		return fmt.Errorf("%w: unexpected SOCKS version %d", ErrProxy, reply[0])
+	}
+	switch reply[1] {
+	case noAuth:
+	case userPassAuth:
+		req := []byte{userPassVersion, byte(len(d.Username))}
+		req = append(req, d.Username...)
+		req = append(req, byte(len(d.Password)))
+		req = append(req, d.Password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != succeeded {
+			return fmt.Errorf("%w: SOCKS authentication failed", ErrProxy)
+		}
+	case noAcceptable:
+		return fmt.Errorf("%w: no acceptable SOCKS authentication method", ErrProxy)
+	default:
+		return fmt.Errorf("%w: unexpected SOCKS authentication method %d", ErrProxy, reply[1])
+	}
+
+	// Connect request
+	req := []byte{version, connectCommand, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		req = append(req, domainAddress, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, ipv4Address)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, ipv6Address)
+		req = append(req, ip.To16()...)
+	}
+	req = binary.BigEndian.AppendUint16(req, port)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != succeeded {
+		return fmt.Errorf("%w: SOCKS connect to %s failed with code %d", ErrProxy, net.JoinHostPort(host, strconv.Itoa(int(port))), header[1])
+	}
+
+	// Discard the bound address
+	var size int
+	switch header[3] {
+	case ipv4Address:
+		size = net.IPv4len
+	case ipv6Address:
+		size = net.IPv6len
+	case domainAddress:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		size = int(length[0])
+	default:
+		return fmt.Errorf("%w: unexpected SOCKS address type %d", ErrProxy, header[3])
+	}
+	_, err := io.ReadFull(conn, make([]byte, size+2))
+	return err
+}
+
+// HTTPConnectDialer dials through an HTTP proxy using the CONNECT method.
+type HTTPConnectDialer struct {
+	// Address of the proxy, with the format <host>:<port>.
+	Address string
+	// Username and Password are sent using basic authentication when set.
+	Username string
+	Password string
+	// Header holds additional headers sent with the CONNECT request.
+	Header http.Header
+	// Forward is used to connect to the proxy. Defaults to a net.Dialer.
+	Forward Dialer
+}
+
+// Dial connects to address through the proxy. Only TCP networks are supported.
+func (d *HTTPConnectDialer) Dial(network, address string) (net.Conn, error) {
+	if _, _, err := splitHostPort(network, address); err != nil {
+		return nil, err
+	}
+
+	conn, err := forward(d.Forward).Dial("tcp", d.Address)
+	if err != nil {
+		return nil, err
+	}
+	c, err := d.connect(conn, address)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (d *HTTPConnectDialer) connect(conn net.Conn, address string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	for k, v := range d.Header {
+		req.Header[k] = v
+	}
+	if d.Username != "" || d.Password != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.Username + ":" + d.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: CONNECT to %s returned %s", ErrProxy, address, resp.Status)
+	}
+
+	// Keep the bytes the proxy may have sent right after its response.
+	if r.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: r}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection whose first bytes were already read in a buffer.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func forward(d Dialer) Dialer {
+	if d == nil {
+		return &net.Dialer{}
+	}
+	return d
+}
+
+func splitHostPort(network string, address string) (string, uint16, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return "", 0, fmt.Errorf("proxy: unsupported network %s", network)
+	}
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("proxy: invalid port %q", portStr)
+	}
+	return host, uint16(port), nil
+}
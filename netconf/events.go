@@ -17,8 +17,10 @@ limitations under the License.
 package netconf
 
 import (
-	"github.com/openshift-telco/go-netconf-client/netconf/message"
+	"sync"
 	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
 )
 
 /**
@@ -43,9 +45,10 @@ func (t EventType) String() string {
 // Callback is a function that can receive events.
 type Callback func(Event)
 
-// Dispatcher objects can register callbacks for specific events, then when
-// those events occur, dispatch them its according callback functions.
+// Dispatcher hands the received messages to the callbacks registered for them. It is safe for concurrent use:
+// callbacks are registered by the callers, and invoked by the reader goroutine.
 type Dispatcher struct {
+	mu        sync.Mutex
 	callbacks map[string]Callback
 }
 
@@ -56,22 +59,44 @@ func (d *Dispatcher) init() {
 
 // Register a callback function for the specified eventID.
 func (d *Dispatcher) Register(eventID string, callback Callback) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	d.callbacks[eventID] = callback
 }
 
 // Remove a callback function for the specified eventID.
 func (d *Dispatcher) Remove(eventID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	delete(d.callbacks, eventID)
 }
 
 // WaitForMessages waits for all messages in the queue to be processed
 // TODO support timeout
 func (d *Dispatcher) WaitForMessages() {
-	for len(d.callbacks) != 0 {
+	for d.pending() != 0 {
 		time.Sleep(1 * time.Second)
 	}
 }
 
+func (d *Dispatcher) pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.callbacks)
+}
+
+// terminate removes every callback, after invoking it with a termination event holding err.
+func (d *Dispatcher) terminate(err error) {
+	d.mu.Lock()
+	callbacks := d.callbacks
+	d.callbacks = make(map[string]Callback)
+	d.mu.Unlock()
+
+	for eventID, callback := range callbacks {
+		callback(&event{eventID: eventID, err: err})
+	}
+}
+
 // Dispatch an event by triggering its associated callback.
 // FIXME manage errors
 func (d *Dispatcher) Dispatch(eventID string, eventType EventType, value interface{}) {
@@ -81,22 +106,24 @@ func (d *Dispatcher) Dispatch(eventID string, eventType EventType, value interfa
 		value:   value,
 	}
 
-	// Dispatch the event to the callback
-	callback := d.callbacks[eventID]
-	if callback == nil {
-		return
-	}
-	callback(e)
-
 	// In case of rpc-reply, auto-remove registration
 	// If it is a notification, we need to keep the registration active
 	// as we can have still receive notification related to the subscriptionID
+	d.mu.Lock()
+	callback := d.callbacks[eventID]
 	switch eventType.String() {
 	case "rpc-reply":
-		d.Remove(eventID)
+		delete(d.callbacks, eventID)
 	case "notification":
 		// NOOP
 	}
+	d.mu.Unlock()
+
+	// Dispatch the event to the callback
+	if callback == nil {
+		return
+	}
+	callback(e)
 }
 
 // Event represents actions that occur during NETCONF exchange. Listeners can
@@ -106,12 +133,18 @@ type Event interface {
 	Value() interface{}
 	RPCReply() *message.RPCReply
 	Notification() *message.Notification
+	// Err returns nil, unless this is a termination event: the session was closed before the expected
+	// rpc-reply was received, or while notifications were expected. It then returns a SessionClosedError,
+	// and the event holds no value. It also returns the error of the RPC interceptors, if any, see
+	// WithRPCInterceptors.
+	Err() error
 }
 
 // event is an internal implementation of the Event interface.
 type event struct {
 	eventID string
 	value   interface{}
+	err     error
 }
 
 // EventID returns the eventID
@@ -124,6 +157,11 @@ func (e *event) Value() interface{} {
 	return e.value
 }
 
+// Err returns the reason of a termination event.
+func (e *event) Err() error {
+	return e.err
+}
+
 // RPCReply returns an RPCReply from the associated value.
 func (e *event) RPCReply() *message.RPCReply {
 	r, ok := e.value.(*message.RPCReply)
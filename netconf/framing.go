@@ -0,0 +1,287 @@
+package netconf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// DefaultChunkSize is the size of the chunks used when streaming a message with the chunked framing,
+	// unless configured otherwise.
+	DefaultChunkSize = 64 * 1024
+	// maxSendChunkSize is the largest chunk sent, as each chunk is buffered in memory along with its header.
+	maxSendChunkSize = 16 * 1024 * 1024
+	// maxChunkSize is the largest chunk-size allowed by the chunked framing
+	// https://datatracker.ietf.org/doc/html/rfc6242#section-4.2
+	maxChunkSize uint64 = 4294967295
+	// maxChunkSizeDigits is the number of digits of maxChunkSize
+	maxChunkSizeDigits = 10
+)
+
+// ChunkError describes a chunked framing protocol violation, and where it was detected.
+// It wraps ErrBadChunk.
+type ChunkError struct {
+	// Offset is the number of bytes of the framed message read before the violation was detected.
+	Offset int64
+	// Reason describes the violation.
+	Reason string
+}
+
+// Error generates a string representation of the framing error
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("%s at offset %d: %s", ErrBadChunk, e.Offset, e.Reason)
+}
+
+// Unwrap returns ErrBadChunk, so errors.Is can be used to check for framing errors.
+func (e *ChunkError) Unwrap() error {
+	return ErrBadChunk
+}
+
+// messageReader reads the content of a single framed NETCONF message.
+type messageReader interface {
+	io.Reader
+	// complete reports whether the end of the message framing was read.
+	complete() bool
+}
+
+// chunkedReader decodes a single message using the NETCONF 1.1 chunked framing.
+// It is a state machine reading chunk headers directly off the wire: chunk data is
+// copied as-is and never scanned for framing markers.
+// https://datatracker.ietf.org/doc/html/rfc6242#section-4.2
+type chunkedReader struct {
+	r *bufio.Reader
+	// remaining is the number of data bytes left in the current chunk
+	remaining uint64
+	chunks    int
+	offset    int64
+	done      bool
+	err       error
+}
+
+func newChunkedReader(r *bufio.Reader) *chunkedReader {
+	return &chunkedReader{r: r}
+}
+
+func (c *chunkedReader) complete() bool {
+	return c.done
+}
+
+// Read reads chunk data until the end-of-chunks marker, after which io.EOF is returned.
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for c.remaining == 0 {
+		if err := c.readHeader(); err != nil {
+			c.err = err
+			return 0, err
+		}
+	}
+
+	if uint64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= uint64(n)
+	c.offset += int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// readHeader reads either a chunk header, `\n#<chunk-size>\n`, or the end-of-chunks marker, `\n##\n`.
+func (c *chunkedReader) readHeader() error {
+	if err := c.expect('\n'); err != nil {
+		return err
+	}
+	if err := c.expect('#'); err != nil {
+		return err
+	}
+
+	b, err := c.readByte()
+	if err != nil {
+		return err
+	}
+	if b == '#' {
+		if err := c.expect('\n'); err != nil {
+			return err
+		}
+		if c.chunks == 0 {
+			return c.errorf("end-of-chunks marker without any chunk")
+		}
+		c.done = true
+		return io.EOF
+	}
+	if b < '1' || b > '9' {
+		return c.errorf("invalid chunk-size first digit %q", b)
+	}
+
+	size := uint64(b - '0')
+	for digits := 1; ; digits++ {
+		b, err = c.readByte()
+		if err != nil {
+			return err
+		}
+		if b == '\n' {
+			break
+		}
+		if b < '0' || b > '9' {
+			return c.errorf("invalid chunk-size digit %q", b)
+		}
+		if digits == maxChunkSizeDigits {
+			return c.errorf("chunk-size exceeds %d digits", maxChunkSizeDigits)
+		}
+		size = size*10 + uint64(b-'0')
+	}
+	if size > maxChunkSize {
+		return c.errorf("chunk-size %d exceeds maximum of %d", size, maxChunkSize)
+	}
+
+	c.remaining = size
+	c.chunks++
+	return nil
+}
+
+func (c *chunkedReader) readByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err != nil {
+		// The peer closing the connection between two messages is not a framing error.
+		if err == io.EOF && c.offset > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	c.offset++
+	return b, nil
+}
+
+func (c *chunkedReader) expect(want byte) error {
+	b, err := c.readByte()
+	if err != nil {
+		return err
+	}
+	if b != want {
+		return c.errorf("expected %q, got %q", want, b)
+	}
+	return nil
+}
+
+func (c *chunkedReader) errorf(format string, args ...any) error {
+	return &ChunkError{Offset: c.offset, Reason: fmt.Sprintf(format, args...)}
+}
+
+// eomReader decodes a single message using the NETCONF 1.0 end-of-message framing.
+// https://datatracker.ietf.org/doc/html/rfc6242#section-4.3
+type eomReader struct {
+	r    *bufio.Reader
+	read int64
+	done bool
+	err  error
+}
+
+func newEOMReader(r *bufio.Reader) *eomReader {
+	return &eomReader{r: r}
+}
+
+func (e *eomReader) complete() bool {
+	return e.done
+}
+
+// Read reads the message until the end-of-message marker, after which io.EOF is returned.
+// The marker is never consumed past, so the next message stays in the buffered reader.
+func (e *eomReader) Read(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	// Make sure enough data is buffered to detect a marker starting anywhere in it.
+	size := len(msgSeparator)
+	if buffered := e.r.Buffered(); buffered > size {
+		size = buffered
+	}
+	buf, err := e.r.Peek(size)
+	if err != nil {
+		if err == io.EOF && (e.read > 0 || len(buf) > 0) {
+			err = io.ErrUnexpectedEOF
+		}
+		e.err = err
+		return 0, err
+	}
+
+	idx := bytes.Index(buf, []byte(msgSeparator))
+	end := len(buf) - len(msgSeparator) + 1
+	if idx >= 0 {
+		end = idx
+	}
+	n := copy(p, buf[:end])
+	_, _ = e.r.Discard(n)
+	e.read += int64(n)
+
+	if idx >= 0 && n == idx {
+		_, _ = e.r.Discard(len(msgSeparator))
+		e.done = true
+		e.err = io.EOF
+	}
+	return n, e.err
+}
+
+// limitChunkSize caps size to maxSendChunkSize.
+func limitChunkSize(size int) int {
+	if size > maxSendChunkSize {
+		return maxSendChunkSize
+	}
+	return size
+}
+
+// writeChunked writes the content of r to w as a single message using the NETCONF 1.1 chunked framing,
+// with chunks of at most chunkSize bytes. Each chunk is written along with its header in a single Write.
+// https://datatracker.ietf.org/doc/html/rfc6242#section-4.2
+func writeChunked(w io.Writer, r io.Reader, chunkSize int) error {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	chunkSize = limitChunkSize(chunkSize)
+	// Reserve room for the largest chunk header in front of the data.
+	const headerSize = len("\n#\n") + maxChunkSizeDigits
+	buf := make([]byte, headerSize+chunkSize)
+
+	chunks := 0
+	for {
+		n, err := io.ReadFull(r, buf[headerSize:])
+		if n > 0 {
+			header := fmt.Sprintf("\n#%d\n", n)
+			start := headerSize - len(header)
+			copy(buf[start:], header)
+			if _, err := w.Write(buf[start : headerSize+n]); err != nil {
+				return err
+			}
+			chunks++
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if chunks == 0 {
+		return errors.New("cannot send an empty message using the chunked framing")
+	}
+	_, err := w.Write([]byte(msgSeparatorV11))
+	return err
+}
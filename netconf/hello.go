@@ -0,0 +1,218 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+)
+
+const (
+	// defaultHelloTimeout bounds the hello exchange, unless configured otherwise using WithHelloTimeout.
+	defaultHelloTimeout = 30 * time.Second
+)
+
+var (
+	// ErrInvalidHello indicates the hello message received from the server is not valid.
+	ErrInvalidHello = errors.New("netconf: invalid server hello")
+	// ErrNoCommonBaseVersion indicates the client and the server do not share any NETCONF base version.
+	ErrNoCommonBaseVersion = errors.New("netconf: no common base version")
+	// ErrHelloTimeout indicates the hello exchange did not complete in time.
+	ErrHelloTimeout = errors.New("netconf: timeout during hello exchange")
+	// ErrHelloExchanged indicates SendHello was asked to advertise capabilities, although the hello messages
+	// were already exchanged advertising other ones.
+	ErrHelloExchanged = errors.New("netconf: hello already exchanged")
+)
+
+// BaseVersionError is returned when the client and the server do not advertise any common
+// NETCONF base version. It wraps ErrNoCommonBaseVersion.
+type BaseVersionError struct {
+	ClientCapabilities []string
+	ServerCapabilities []string
+}
+
+// Error generates a string representation of the negotiation failure
+func (e *BaseVersionError) Error() string {
+	return fmt.Sprintf("%s: client advertised %v, server advertised %v",
+		ErrNoCommonBaseVersion, baseVersions(e.ClientCapabilities), baseVersions(e.ServerCapabilities))
+}
+
+// Unwrap returns ErrNoCommonBaseVersion, so errors.Is can be used to check for negotiation failures.
+func (e *BaseVersionError) Unwrap() error {
+	return ErrNoCommonBaseVersion
+}
+
+// WithClientCapabilities sets the capabilities advertised in the client hello. Defaults to DefaultCapabilities.
+// At least one NETCONF base version must be part of them.
+func WithClientCapabilities(capabilities ...string) SessionOption {
+	return func(s *Session) {
+		s.ClientCapabilities = capabilities
+	}
+}
+
+// WithVendorCapabilities advertises additional capabilities in the client hello, such as vendor specific
+// capability URIs, on top of the client capabilities.
+func WithVendorCapabilities(capabilities ...string) SessionOption {
+	return func(s *Session) {
+		s.vendorCapabilities = append(s.vendorCapabilities, capabilities...)
+	}
+}
+
+// WithDeferredHello restores the former two-step session establishment, for compatibility: NewSession returns
+// without exchanging the hello messages, and SendHello must be called before sending any RPC.
+func WithDeferredHello() SessionOption {
+	return func(s *Session) {
+		s.deferHello.Store(true)
+	}
+}
+
+// WithHelloTimeout bounds the hello exchange. Defaults to 30 seconds; zero disables the timeout.
+func WithHelloTimeout(timeout time.Duration) SessionOption {
+	return func(s *Session) {
+		s.helloTimeout = timeout
+	}
+}
+
+// handshake exchanges the hello messages with the server, and switches the transport to the framing of the
+// negotiated base version. As allowed by RFC6241, both hello messages are sent simultaneously.
+// https://datatracker.ietf.org/doc/html/rfc6241#section-8.1
+func (session *Session) handshake() error {
+	session.ClientCapabilities = mergeCapabilities(session.ClientCapabilities, session.vendorCapabilities)
+
+	type received struct {
+		hello *message.Hello
+		err   error
+	}
+	sent := make(chan error, 1)
+	recv := make(chan received, 1)
+
+	go func() {
+		sent <- session.sendHello(&message.Hello{Capabilities: session.ClientCapabilities})
+	}()
+	go func() {
+		hello, err := session.ReceiveHello()
+		recv <- received{hello, err}
+	}()
+
+	var timeout <-chan time.Time
+	if session.helloTimeout > 0 {
+		timer := time.NewTimer(session.helloTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var serverHello *message.Hello
+	for pending := 2; pending > 0; pending-- {
+		select {
+		case err := <-sent:
+			if err != nil {
+				return fmt.Errorf("failed to send hello: %w", err)
+			}
+		case r := <-recv:
+			if r.err != nil {
+				return fmt.Errorf("failed to receive hello: %w", r.err)
+			}
+			serverHello = r.hello
+		case <-timeout:
+			// Closing the transport unblocks the pending send or receive.
+			_ = session.Transport.Close()
+			return fmt.Errorf("%w after %s", ErrHelloTimeout, session.helloTimeout)
+		}
+	}
+
+	if serverHello.SessionID <= 0 {
+		return fmt.Errorf("%w: missing or invalid session-id", ErrInvalidHello)
+	}
+	version, err := negotiateBaseVersion(session.ClientCapabilities, serverHello.Capabilities)
+	if err != nil {
+		return err
+	}
+
+	session.SessionID = serverHello.SessionID
+	session.Capabilities = serverHello.Capabilities
+	session.serverCapabilities = message.ParseCapabilities(serverHello.Capabilities)
+	session.BaseVersion = version
+
+	// Set Transport version after the hello exchange,
+	// so the hello-messages are sent using netconf:1.0 framing
+	if version == message.NetconfVersion11 {
+		session.Transport.SetVersion("v1.1")
+	} else {
+		session.Transport.SetVersion("v1.0")
+	}
+	return nil
+}
+
+// sendHello marshals and sends the hello message.
+func (session *Session) sendHello(hello *message.Hello) error {
+	val, err := xml.Marshal(hello)
+	if err != nil {
+		return err
+	}
+
+	header := []byte(xml.Header)
+	val = append(header, val...)
+	session.trace(TraceSent, val)
+	if err := session.Transport.Send(val); err != nil {
+		session.traceSendError("", err)
+		return err
+	}
+	return nil
+}
+
+// negotiateBaseVersion returns the highest NETCONF base version advertised by both the client and the server.
+func negotiateBaseVersion(client []string, server []string) (string, error) {
+	for _, version := range []string{message.NetconfVersion11, message.NetconfVersion10} {
+		if contains(client, version) && contains(server, version) {
+			return version, nil
+		}
+	}
+	return "", &BaseVersionError{ClientCapabilities: client, ServerCapabilities: server}
+}
+
+func baseVersions(capabilities []string) []string {
+	var versions []string
+	for _, version := range []string{message.NetconfVersion10, message.NetconfVersion11} {
+		if contains(capabilities, version) {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sameCapabilities reports whether both lists hold the same capabilities, regardless of their order.
+func sameCapabilities(a []string, b []string) bool {
+	for _, c := range a {
+		if !contains(b, c) {
+			return false
+		}
+	}
+	for _, c := range b {
+		if !contains(a, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeCapabilities returns the capabilities followed by the additional ones not already part of them.
+func mergeCapabilities(capabilities []string, additional []string) []string {
+	merged := append([]string(nil), capabilities...)
+	for _, c := range additional {
+		if !contains(merged, c) {
+			merged = append(merged, c)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,231 @@
+package netconf
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var (
+	// ErrHostKeyUnknown indicates no host key is known for the server.
+	ErrHostKeyUnknown = errors.New("ssh: unknown host key")
+	// ErrHostKeyMismatch indicates the server presented a host key different from the known one.
+	// It may be the sign of a man-in-the-middle attack.
+	ErrHostKeyMismatch = errors.New("ssh: host key mismatch")
+	// ErrHostKeyRevoked indicates the server presented a host key marked as revoked.
+	ErrHostKeyRevoked = errors.New("ssh: host key revoked")
+	// ErrHostCertificateInvalid indicates the server presented a host certificate which is not valid, or
+	// which is not signed by a known certificate authority.
+	ErrHostCertificateInvalid = errors.New("ssh: invalid host certificate")
+)
+
+// HostKeyError reports a host key verification failure. It wraps one of ErrHostKeyUnknown, ErrHostKeyMismatch,
+// ErrHostKeyRevoked or ErrHostCertificateInvalid. Note that the ssh package does not wrap the errors returned
+// by the host key callback, so they can only be inspected from the callback itself.
+type HostKeyError struct {
+	// Reason is the sentinel error describing the failure.
+	Reason error
+	// Hostname and Remote are the server address, as provided to the host key callback.
+	Hostname string
+	Remote   net.Addr
+	// Key is the host key presented by the server.
+	Key ssh.PublicKey
+	// Known holds the host keys expected from the server, if any.
+	Known []ssh.PublicKey
+	// Err holds the underlying error, if any.
+	Err error
+}
+
+// Error generates a string representation of the verification failure
+func (e *HostKeyError) Error() string {
+	msg := fmt.Sprintf("%s: %s presented %s key %s", e.Reason, e.Hostname, e.Key.Type(), ssh.FingerprintSHA256(e.Key))
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error describing the failure.
+func (e *HostKeyError) Unwrap() error {
+	return e.Reason
+}
+
+// KnownHostsCallback returns a host key callback verifying the server against OpenSSH known_hosts files,
+// including hashed host names, @cert-authority and @revoked lines.
+func KnownHostsCallback(files ...string) (ssh.HostKeyCallback, error) {
+	callback, err := knownhosts.New(files...)
+	if err != nil {
+		return nil, err
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		hostKeyErr := &HostKeyError{Hostname: hostname, Remote: remote, Key: key}
+		var keyErr *knownhosts.KeyError
+		var revokedErr *knownhosts.RevokedError
+		switch {
+		case errors.As(err, &keyErr):
+			hostKeyErr.Reason = ErrHostKeyUnknown
+			if len(keyErr.Want) > 0 {
+				hostKeyErr.Reason = ErrHostKeyMismatch
+			}
+			for _, known := range keyErr.Want {
+				hostKeyErr.Known = append(hostKeyErr.Known, known.Key)
+			}
+		case errors.As(err, &revokedErr):
+			hostKeyErr.Reason = ErrHostKeyRevoked
+		default:
+			if _, ok := key.(*ssh.Certificate); !ok {
+				return err
+			}
+			hostKeyErr.Reason = ErrHostCertificateInvalid
+			hostKeyErr.Err = err
+		}
+		return hostKeyErr
+	}, nil
+}
+
+// PinnedHostKeyCallback returns a host key callback accepting only the pinned host keys. pins maps every target,
+// as provided when dialing (e.g. 172.16.1.1:830) or as a bare host (e.g. 172.16.1.1), to the fingerprints of the
+// host keys it is expected to present. Fingerprints use either the SHA256 format of ssh.FingerprintSHA256
+// (e.g. SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s) or the legacy MD5 format.
+func PinnedHostKeyCallback(pins map[string][]string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprints, ok := lookupPins(pins, hostname)
+		if !ok {
+			return &HostKeyError{Reason: ErrHostKeyUnknown, Hostname: hostname, Remote: remote, Key: key}
+		}
+		sha256, md5 := ssh.FingerprintSHA256(key), ssh.FingerprintLegacyMD5(key)
+		for _, fingerprint := range fingerprints {
+			if fingerprint == sha256 || strings.EqualFold(fingerprint, md5) {
+				return nil
+			}
+		}
+		return &HostKeyError{Reason: ErrHostKeyMismatch, Hostname: hostname, Remote: remote, Key: key}
+	}
+}
+
+func lookupPins(pins map[string][]string, hostname string) ([]string, bool) {
+	candidates := []string{hostname, knownhosts.Normalize(hostname)}
+	if host, _, err := net.SplitHostPort(hostname); err == nil {
+		candidates = append(candidates, host)
+	}
+	for _, candidate := range candidates {
+		if fingerprints, ok := pins[candidate]; ok {
+			return fingerprints, true
+		}
+	}
+	return nil, false
+}
+
+// HostKeyStore records the host keys trusted on first use, see TOFUHostKeyCallback.
+type HostKeyStore interface {
+	// Lookup returns the host keys known for the server, or none if the server is unknown.
+	Lookup(hostname string, remote net.Addr) ([]ssh.PublicKey, error)
+	// Add records key as a host key of the server.
+	Add(hostname string, remote net.Addr, key ssh.PublicKey) error
+}
+
+// TOFUHostKeyCallback returns a host key callback trusting the host key presented by a server the first
+// time it is met, recording it in store. Afterwards, the server must keep presenting one of the recorded keys.
+// The callback serializes the lookup and the recording of the keys, so among concurrent first connections to
+// a server, only one key is trusted: the store should thus not be shared by several callbacks.
+func TOFUHostKeyCallback(store HostKeyStore) ssh.HostKeyCallback {
+	var mu sync.Mutex
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		known, err := store.Lookup(hostname, remote)
+		if err != nil {
+			return err
+		}
+		if len(known) == 0 {
+			return store.Add(hostname, remote, key)
+		}
+		for _, k := range known {
+			if bytes.Equal(k.Marshal(), key.Marshal()) {
+				return nil
+			}
+		}
+		return &HostKeyError{Reason: ErrHostKeyMismatch, Hostname: hostname, Remote: remote, Key: key, Known: known}
+	}
+}
+
+// FileHostKeyStore is a HostKeyStore backed by a file using the OpenSSH known_hosts format.
+type FileHostKeyStore struct {
+	// Path of the known_hosts file, created when the first host key is added.
+	Path string
+	// HashHostnames records hashed host names, as the HashKnownHosts option of OpenSSH.
+	HashHostnames bool
+
+	mu sync.Mutex
+}
+
+// NewFileHostKeyStore returns a HostKeyStore backed by the known_hosts file at path.
+func NewFileHostKeyStore(path string) *FileHostKeyStore {
+	return &FileHostKeyStore{Path: path}
+}
+
+// probeHostKey is a key never found in a known_hosts file, used to retrieve the keys known for a host.
+var probeHostKey, _ = ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+
+// Lookup returns the host keys known for the server, one per key type.
+func (s *FileHostKeyStore) Lookup(hostname string, remote net.Addr) ([]ssh.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(s.Path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	callback, err := knownhosts.New(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	if remote == nil {
+		remote = &net.TCPAddr{}
+	}
+
+	// Checking a key which cannot be known reports the keys known for the host.
+	var keyErr *knownhosts.KeyError
+	if err := callback(hostname, remote, probeHostKey); !errors.As(err, &keyErr) {
+		return nil, err
+	}
+	keys := make([]ssh.PublicKey, 0, len(keyErr.Want))
+	for _, known := range keyErr.Want {
+		keys = append(keys, known.Key)
+	}
+	return keys, nil
+}
+
+// Add appends the host key of the server to the known_hosts file.
+func (s *FileHostKeyStore) Add(hostname string, _ net.Addr, key ssh.PublicKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	host := knownhosts.Normalize(hostname)
+	if s.HashHostnames {
+		host = knownhosts.HashHostname(host)
+	}
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, knownhosts.Line([]string{host}, key)); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
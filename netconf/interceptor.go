@@ -0,0 +1,174 @@
+package netconf
+
+import (
+	"context"
+	"sync"
+
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+)
+
+// RPCInfo describes the RPC executed through the RPC interceptors.
+type RPCInfo struct {
+	// Operation is the RPC being executed.
+	Operation message.RPCMethod
+	// Request is the marshalled operation, including the XML declaration. Interceptors may replace it, e.g. to
+	// add vendor attributes: the replacement is sent as is. It is nil for operations implementing io.Reader,
+	// such as message.RPCStream, which are streamed from the operation.
+	Request []byte
+}
+
+// RPCInvoker executes the RPC: it sends the request and waits for its reply.
+type RPCInvoker func(ctx context.Context, info *RPCInfo) (*message.RPCReply, error)
+
+// RPCInterceptor intercepts the execution of an RPC using SyncRPC or AsyncRPC, and their variants. It is
+// responsible for calling invoker to execute the RPC, and may inspect or change the request, the reply and
+// the error, or return without calling invoker at all, e.g. to inject faults.
+type RPCInterceptor func(ctx context.Context, info *RPCInfo, invoker RPCInvoker) (*message.RPCReply, error)
+
+// NotificationHandler delivers a notification to the callback registered for it.
+type NotificationHandler func(notification *message.Notification)
+
+// NotificationInterceptor intercepts the delivery of a received notification. It is responsible for calling
+// handler to deliver the notification, and may inspect or change it, or drop it by not calling handler.
+// Interceptors are invoked by the goroutine reading the incoming messages, and should not block.
+type NotificationInterceptor func(notification *message.Notification, handler NotificationHandler)
+
+// WithRPCInterceptors adds interceptors around every RPC executed using SyncRPC or AsyncRPC, and their
+// variants. The first interceptor is the outermost one.
+//
+// When interceptors are set, AsyncRPC executes them in a new goroutine. It returns once the request is sent,
+// or with the error returned by the interceptors when they did not send it. The callback then receives the
+// reply, or the error, returned by the interceptors. It is never invoked once AsyncRPC returned an error, such
+// as the failure to send the request, even when the interceptors retry it.
+func WithRPCInterceptors(interceptors ...RPCInterceptor) SessionOption {
+	return func(s *Session) {
+		s.rpcInterceptors = append(s.rpcInterceptors, interceptors...)
+	}
+}
+
+// WithNotificationInterceptors adds interceptors around the delivery of every received notification. The first
+// interceptor is the outermost one.
+func WithNotificationInterceptors(interceptors ...NotificationInterceptor) SessionOption {
+	return func(s *Session) {
+		s.notificationInterceptors = append(s.notificationInterceptors, interceptors...)
+	}
+}
+
+// chainRPCInterceptors returns the interceptors combined into a single one, or nil when there are none.
+func chainRPCInterceptors(interceptors []RPCInterceptor) RPCInterceptor {
+	if len(interceptors) == 0 {
+		return nil
+	}
+	return func(ctx context.Context, info *RPCInfo, invoker RPCInvoker) (*message.RPCReply, error) {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], invoker
+			invoker = func(ctx context.Context, info *RPCInfo) (*message.RPCReply, error) {
+				return interceptor(ctx, info, next)
+			}
+		}
+		return invoker(ctx, info)
+	}
+}
+
+// chainNotificationInterceptors returns the interceptors combined into a single one, or nil when there are none.
+func chainNotificationInterceptors(interceptors []NotificationInterceptor) NotificationInterceptor {
+	if len(interceptors) == 0 {
+		return nil
+	}
+	return func(notification *message.Notification, handler NotificationHandler) {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], handler
+			handler = func(notification *message.Notification) {
+				interceptor(notification, next)
+			}
+		}
+		handler(notification)
+	}
+}
+
+// newRPCInfo marshals the operation, unless it is streamed.
+func newRPCInfo(operation message.RPCMethod) (*RPCInfo, error) {
+	info := &RPCInfo{Operation: operation}
+	if !isStreamed(operation) {
+		request, err := marshall(operation)
+		if err != nil {
+			return nil, err
+		}
+		info.Request = request
+	}
+	return info, nil
+}
+
+// invoke executes the RPC through the RPC interceptors. sent, when set, is invoked once the request is sent,
+// with the error met sending it.
+func (session *Session) invoke(ctx context.Context, info *RPCInfo, o *callOptions, sent func(error)) (*message.RPCReply, error) {
+	invoker := func(ctx context.Context, info *RPCInfo) (*message.RPCReply, error) {
+		return session.roundTrip(ctx, info, o, sent)
+	}
+	if session.rpcInterceptor == nil {
+		return invoker(ctx, info)
+	}
+	return session.rpcInterceptor(ctx, info, invoker)
+}
+
+// asyncInvoke executes the RPC through the RPC interceptors in a new goroutine, invoking callback with the
+// result. It returns once the request is sent, or once the interceptors returned without sending it.
+func (session *Session) asyncInvoke(
+	ctx context.Context, cancel context.CancelFunc, release func(), operation message.RPCMethod, callback Callback, o *callOptions,
+) error {
+	info, err := newRPCInfo(operation)
+	if err != nil {
+		cancel()
+		release()
+		return err
+	}
+
+	var once sync.Once
+	var sendErr error
+	returned := make(chan error, 1)
+	sent := func(err error) {
+		once.Do(func() {
+			sendErr = err
+			returned <- err
+		})
+	}
+
+	go func() {
+		defer cancel()
+		defer release()
+
+		reply, err := session.invoke(ctx, info, o, sent)
+		// The interceptors may return without sending the request, AsyncRPC then returns their error.
+		notSent := false
+		once.Do(func() {
+			notSent = true
+			returned <- err
+		})
+		switch {
+		case sendErr != nil, notSent && err != nil:
+			// The error was returned by AsyncRPC, even when the interceptors retried the RPC afterwards.
+			return
+		case err != nil && ctx.Err() != nil:
+			// The RPC was abandoned, as for AsyncRPC without interceptors the callback is not invoked.
+			return
+		case err != nil:
+			callback(&event{eventID: operation.GetMessageID(), err: err})
+		default:
+			callback(&event{eventID: operation.GetMessageID(), value: reply})
+		}
+	}()
+	return <-returned
+}
+
+// dispatchNotification delivers the notification to the callback registered for id, through the notification
+// interceptors.
+func (session *Session) dispatchNotification(id string, notification *message.Notification) {
+	handler := func(notification *message.Notification) {
+		session.Listener.Dispatch(id, 1, notification)
+	}
+	if session.notificationInterceptor == nil {
+		handler(notification)
+		return
+	}
+	session.notificationInterceptor(notification, handler)
+}
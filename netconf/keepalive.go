@@ -0,0 +1,171 @@
+package netconf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// sshKeepaliveRequest is the global request sent to probe the peer, as OpenSSH does
+	sshKeepaliveRequest = "keepalive@openssh.com"
+	// defaultKeepaliveInterval is used when KeepaliveConfig.Interval is not set
+	defaultKeepaliveInterval = 30 * time.Second
+	// defaultKeepaliveMaxMissed is used when KeepaliveConfig.MaxMissed is not set
+	defaultKeepaliveMaxMissed = 3
+)
+
+// ErrPeerUnresponsive indicates the connection was closed because the peer stopped answering keepalive requests.
+var ErrPeerUnresponsive = errors.New("ssh: peer not responding to keepalive requests")
+
+// KeepaliveConfig configures the probing of the peer, so a dead peer is detected even when the session is idle,
+// such as a session waiting for notifications. The peer is considered dead, and the connection closed, after
+// MaxMissed consecutive keepalive requests are left unanswered: the idle time tolerated without any sign of life
+// from the peer is thus about Interval * MaxMissed, independently of the timeout of each RPC.
+type KeepaliveConfig struct {
+	// Interval between two keepalive requests. Defaults to 30 seconds.
+	Interval time.Duration
+	// MaxMissed is the number of consecutive keepalive requests left unanswered after which the peer
+	// is considered dead. Defaults to 3.
+	MaxMissed int
+}
+
+// WithKeepalive probes the peer as configured, for transports supporting it such as TransportSSH.
+// When the peer is considered dead, the session is closed, and the reason is returned by Err on the transport.
+func WithKeepalive(config KeepaliveConfig) SessionOption {
+	return func(s *Session) {
+		s.keepalive = &config
+	}
+}
+
+// keepaliveTransport is implemented by transports able to probe their peer.
+type keepaliveTransport interface {
+	StartKeepalive(config KeepaliveConfig)
+}
+
+// StartKeepalive starts probing the peer as configured, until the transport is closed. The keepalive applies to
+// the SSH connection, and is thus shared by the transports opened using OpenChannel. When it is already running,
+// e.g. as started by DialSSHTimeout, it is restarted using the new configuration.
+func (t *TransportSSH) StartKeepalive(config KeepaliveConfig) {
+	if config.Interval <= 0 {
+		config.Interval = defaultKeepaliveInterval
+	}
+	if config.MaxMissed <= 0 {
+		config.MaxMissed = defaultKeepaliveMaxMissed
+	}
+
+	c := t.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.count == 0 {
+		return
+	}
+	if c.keepaliveStop != nil {
+		close(c.keepaliveStop)
+	}
+	c.keepaliveStop = make(chan struct{})
+	go c.keepalive(config, c.keepaliveStop)
+}
+
+// Err returns the reason the SSH connection was closed when the peer was considered dead,
+// or nil if the connection is alive or was closed by the client.
+func (t *TransportSSH) Err() error {
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.Err()
+}
+
+func (c *sshConn) keepalive(config KeepaliveConfig, stop <-chan struct{}) {
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+
+	replies := make(chan error, 1)
+	pending := false
+	missed := 0
+	for {
+		if !pending {
+			pending = true
+			go func() {
+				// Any reply, even a failure, shows the peer is alive.
+				_, _, err := c.client.SendRequest(sshKeepaliveRequest, true, nil)
+				replies <- err
+			}()
+		}
+
+		select {
+		case <-stop:
+			return
+		case err := <-replies:
+			if err != nil {
+				// The connection is closed
+				return
+			}
+			pending = false
+			missed = 0
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		case <-ticker.C:
+			missed++
+			if missed >= config.MaxMissed {
+				c.fail(fmt.Errorf("%w: no reply for %s", ErrPeerUnresponsive, time.Duration(missed)*config.Interval))
+				return
+			}
+		}
+	}
+}
+
+// fail closes the connection, recording the reason.
+func (c *sshConn) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+	_ = c.client.Close()
+}
+
+// Err returns the reason the connection was closed, if it was not closed by the client.
+func (c *sshConn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// sshConnReader reads from an SSH channel, returning the reason the connection was closed
+// instead of the resulting read error.
+type sshConnReader struct {
+	io.Reader
+	conn *sshConn
+}
+
+func (r *sshConnReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil {
+		if connErr := r.conn.Err(); connErr != nil {
+			return n, connErr
+		}
+	}
+	return n, err
+}
+
+// sshConnWriter writes to an SSH channel, returning the reason the connection was closed
+// instead of the resulting write error.
+type sshConnWriter struct {
+	io.WriteCloser
+	conn *sshConn
+}
+
+func (w *sshConnWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	if err != nil {
+		if connErr := w.conn.Err(); connErr != nil {
+			return n, connErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,224 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// CapabilityWritableRunning is the URI of the `:writable-running` capability
+	CapabilityWritableRunning = "urn:ietf:params:netconf:capability:writable-running:1.0"
+	// CapabilityCandidate is the URI of the `:candidate` capability
+	CapabilityCandidate = "urn:ietf:params:netconf:capability:candidate:1.0"
+	// CapabilityConfirmedCommit is the URI of the `:confirmed-commit` capability
+	CapabilityConfirmedCommit = "urn:ietf:params:netconf:capability:confirmed-commit:1.1"
+	// CapabilityRollbackOnError is the URI of the `:rollback-on-error` capability
+	CapabilityRollbackOnError = "urn:ietf:params:netconf:capability:rollback-on-error:1.0"
+	// CapabilityValidate is the URI of the `:validate` capability
+	CapabilityValidate = "urn:ietf:params:netconf:capability:validate:1.1"
+	// CapabilityStartup is the URI of the `:startup` capability
+	CapabilityStartup = "urn:ietf:params:netconf:capability:startup:1.0"
+	// CapabilityURL is the URI of the `:url` capability, advertised with the supported schemes
+	CapabilityURL = "urn:ietf:params:netconf:capability:url:1.0"
+	// CapabilityXPath is the URI of the `:xpath` capability
+	CapabilityXPath = "urn:ietf:params:netconf:capability:xpath:1.0"
+	// CapabilityNotification is the URI of the `:notification` capability
+	CapabilityNotification = "urn:ietf:params:netconf:capability:notification:1.0"
+	// CapabilityInterleave is the URI of the `:interleave` capability
+	CapabilityInterleave = "urn:ietf:params:netconf:capability:interleave:1.0"
+	// CapabilityWithDefaults is the URI of the `:with-defaults` capability, advertised with the supported modes
+	CapabilityWithDefaults = "urn:ietf:params:netconf:capability:with-defaults:1.0"
+
+	// capabilityPrefix is the common prefix of the NETCONF capabilities URIs
+	capabilityPrefix = "urn:ietf:params:netconf:capability:"
+)
+
+// ErrCapabilityNotSupported indicates an operation requires a capability the server did not advertise.
+var ErrCapabilityNotSupported = errors.New("netconf: capability not supported by the server")
+
+// CapabilityError is returned when an operation requires a capability the server did not advertise.
+// It wraps ErrCapabilityNotSupported.
+type CapabilityError struct {
+	// Operation is the type of the rejected message, e.g. `*message.Commit`
+	Operation string
+	// Capability is the URI of the missing capability
+	Capability string
+}
+
+// Error generates a string representation of the missing capability
+func (e *CapabilityError) Error() string {
+	return fmt.Sprintf("%s: %s requires %s", ErrCapabilityNotSupported, e.Operation, e.Capability)
+}
+
+// Unwrap returns ErrCapabilityNotSupported
+func (e *CapabilityError) Unwrap() error {
+	return ErrCapabilityNotSupported
+}
+
+// Capability is a capability advertised in a hello message.
+// https://datatracker.ietf.org/doc/html/rfc6241#section-8
+type Capability struct {
+	// URI is the capability as advertised, including its parameters
+	URI string
+	// Name is the short name of a NETCONF capability, e.g. `:candidate`, or the base URI of any other capability
+	Name string
+	// Version is the version of a NETCONF capability, e.g. `1.0`
+	Version string
+	// Parameters are the query parameters of the capability, e.g. `scheme` for `:url`
+	Parameters url.Values
+}
+
+// Module is a YANG module advertised as a capability.
+// https://datatracker.ietf.org/doc/html/rfc6020#section-5.6.4
+type Module struct {
+	Namespace  string
+	Name       string
+	Revision   string
+	Features   []string
+	Deviations []string
+}
+
+// HasFeature reports whether the feature of the module is supported.
+func (m *Module) HasFeature(feature string) bool {
+	for _, f := range m.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Capabilities are the capabilities advertised in a hello message, parsed into NETCONF capabilities and YANG
+// modules.
+type Capabilities struct {
+	capabilities []Capability
+	modules      map[string]*Module
+}
+
+// ParseCapabilities parses the capabilities advertised in a hello message. Empty capabilities are ignored.
+func ParseCapabilities(uris []string) *Capabilities {
+	c := &Capabilities{modules: make(map[string]*Module)}
+	for _, uri := range uris {
+		uri = strings.TrimSpace(uri)
+		if uri == "" {
+			continue
+		}
+		capability := parseCapability(uri)
+		c.capabilities = append(c.capabilities, capability)
+
+		if name := capability.Parameters.Get("module"); name != "" {
+			c.modules[name] = &Module{
+				Namespace:  capability.Name,
+				Name:       name,
+				Revision:   capability.Parameters.Get("revision"),
+				Features:   list(capability.Parameters.Get("features")),
+				Deviations: list(capability.Parameters.Get("deviations")),
+			}
+		}
+	}
+	return c
+}
+
+func parseCapability(uri string) Capability {
+	base, query, _ := strings.Cut(uri, "?")
+	// Parameters are sometimes advertised with their XML escaping, e.g. `&amp;`.
+	parameters, _ := url.ParseQuery(strings.ReplaceAll(query, "&amp;", "&"))
+	capability := Capability{URI: uri, Name: base, Parameters: parameters}
+
+	if name, found := strings.CutPrefix(base, capabilityPrefix); found {
+		if i := strings.LastIndex(name, ":"); i > 0 {
+			capability.Name = ":" + name[:i]
+			capability.Version = name[i+1:]
+		}
+	}
+	return capability
+}
+
+// list splits a comma separated list of values.
+func list(values string) []string {
+	if values == "" {
+		return nil
+	}
+	return strings.Split(values, ",")
+}
+
+// Has reports whether the capability was advertised. The capability is either a URI, whose parameters are
+// ignored, e.g. CapabilityCandidate or a module namespace, or the short name of a NETCONF capability in any
+// version, e.g. `:candidate`.
+func (c *Capabilities) Has(capability string) bool {
+	_, found := c.Capability(capability)
+	return found
+}
+
+// Capability returns the advertised capability, looked up as described by Has.
+func (c *Capabilities) Capability(capability string) (Capability, bool) {
+	base, _, _ := strings.Cut(capability, "?")
+	for _, advertised := range c.capabilities {
+		uri, _, _ := strings.Cut(advertised.URI, "?")
+		if uri == base || advertised.Name == base {
+			return advertised, true
+		}
+	}
+	return Capability{}, false
+}
+
+// All returns the advertised capabilities, in the order they were advertised.
+func (c *Capabilities) All() []Capability {
+	return append([]Capability(nil), c.capabilities...)
+}
+
+// Module returns the advertised YANG module with the provided name.
+func (c *Capabilities) Module(name string) (*Module, bool) {
+	m, found := c.modules[name]
+	return m, found
+}
+
+// Modules returns the advertised YANG modules, sorted by name.
+func (c *Capabilities) Modules() []*Module {
+	modules := make([]*Module, 0, len(c.modules))
+	for _, m := range c.modules {
+		modules = append(modules, m)
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Name < modules[j].Name })
+	return modules
+}
+
+// URLSchemes returns the schemes supported by the `:url` capability, e.g. `file` or `https`.
+// https://datatracker.ietf.org/doc/html/rfc6241#section-8.8
+func (c *Capabilities) URLSchemes() []string {
+	capability, _ := c.Capability(CapabilityURL)
+	return list(capability.Parameters.Get("scheme"))
+}
+
+// WithDefaults returns the basic mode and the additionally supported modes of the `:with-defaults` capability.
+// The basic mode is empty when the capability was not advertised.
+// https://datatracker.ietf.org/doc/html/rfc6243#section-4
+func (c *Capabilities) WithDefaults() (basicMode string, alsoSupported []string) {
+	capability, _ := c.Capability(CapabilityWithDefaults)
+	return capability.Parameters.Get("basic-mode"), list(capability.Parameters.Get("also-supported"))
+}
+
+// CapabilityRequirer is implemented by the messages which can only be sent when the server advertised
+// some capabilities.
+type CapabilityRequirer interface {
+	// RequiredCapabilities returns the URIs of the capabilities required by the message.
+	RequiredCapabilities() []string
+}
+
+// Check returns a *CapabilityError when the operation requires a capability the server did not advertise.
+// Operations not implementing CapabilityRequirer are always accepted.
+func (c *Capabilities) Check(operation RPCMethod) error {
+	requirer, ok := operation.(CapabilityRequirer)
+	if !ok {
+		return nil
+	}
+	for _, capability := range requirer.RequiredCapabilities() {
+		if !c.Has(capability) {
+			return &CapabilityError{Operation: fmt.Sprintf("%T", operation), Capability: capability}
+		}
+	}
+	return nil
+}
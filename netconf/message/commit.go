@@ -30,3 +30,8 @@ func NewCommit() *Commit {
 	rpc.MessageID = uuid()
 	return &rpc
 }
+
+// RequiredCapabilities returns the `:candidate` capability, as commit applies the candidate configuration.
+func (rpc *Commit) RequiredCapabilities() []string {
+	return []string{":candidate"}
+}
@@ -113,3 +113,19 @@ func validateFilterType(filterType string) {
 		fmt.Errorf("provided filterType is not valid: %s. Expecting `%s`", filterType, FilterTypeSubtree),
 	)
 }
+
+// requiredCapabilities returns the capability required to use the datastore, or to write to it when writable
+// is set. The running datastore can always be read.
+func (d *Datastore) requiredCapabilities(writable bool) []string {
+	switch {
+	case d == nil:
+		return nil
+	case d.Candidate != nil:
+		return []string{":candidate"}
+	case d.Startup != nil:
+		return []string{":startup"}
+	case d.Running != nil && writable:
+		return []string{":writable-running"}
+	}
+	return nil
+}
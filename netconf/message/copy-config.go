@@ -16,3 +16,9 @@ func NewCopyConfig(target string, source string) *CopyConfig {
 	rpc.MessageID = uuid()
 	return &rpc
 }
+
+// RequiredCapabilities returns the capabilities of the source datastore, and of the target datastore which must
+// be writable.
+func (rpc *CopyConfig) RequiredCapabilities() []string {
+	return append(rpc.Source.requiredCapabilities(false), rpc.Target.requiredCapabilities(true)...)
+}
@@ -69,3 +69,8 @@ func validDefaultOperation(operation string) {
 		),
 	)
 }
+
+// RequiredCapabilities returns the capability of the target datastore, which must be writable.
+func (rpc *EditConfig) RequiredCapabilities() []string {
+	return rpc.Target.requiredCapabilities(true)
+}
@@ -41,3 +41,8 @@ func NewGetConfig(datastoreType string, filterType string, filterData string) *G
 	rpc.MessageID = uuid()
 	return &rpc
 }
+
+// RequiredCapabilities returns the capability of the source datastore.
+func (rpc *GetConfig) RequiredCapabilities() []string {
+	return rpc.Source.requiredCapabilities(false)
+}
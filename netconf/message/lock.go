@@ -30,3 +30,8 @@ func NewLock(datastoreType string) *Lock {
 	rpc.MessageID = uuid()
 	return &rpc
 }
+
+// RequiredCapabilities returns the capability of the target datastore.
+func (rpc *Lock) RequiredCapabilities() []string {
+	return rpc.Target.requiredCapabilities(false)
+}
@@ -100,6 +100,12 @@ func NewCreateSubscription(stopTime string, startTime string, stream string) *Cr
 	return &rpc
 }
 
+// RequiredCapabilities returns the `:notification` capability.
+// https://datatracker.ietf.org/doc/html/rfc5277#section-3.1
+func (rpc *CreateSubscription) RequiredCapabilities() []string {
+	return []string{":notification"}
+}
+
 // EstablishSubscription represents the NETCONF `establish-subscription` message.
 // https://datatracker.ietf.org/doc/html/rfc8639#section-2.4.2
 // FIXME very very weak implementation: there is no validation made on the schema
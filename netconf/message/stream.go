@@ -0,0 +1,48 @@
+package message
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RPCStream is an RPC whose content is read from an io.Reader while it is sent, instead of being marshalled
+// in memory. It is meant for very large payloads, such as configurations generated from templates.
+type RPCStream struct {
+	MessageID string
+	io.Reader
+}
+
+// GetMessageID returns the message-id of the RPC
+func (rpc *RPCStream) GetMessageID() string {
+	return rpc.MessageID
+}
+
+// NewRPCStream formats an RPC message whose content is read from data.
+// data is sent as-is, hence must be valid XML.
+func NewRPCStream(data io.Reader) *RPCStream {
+	var rpc RPCStream
+	rpc.MessageID = uuid()
+	rpc.Reader = io.MultiReader(
+		strings.NewReader(fmt.Sprintf(`<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%s">`, rpc.MessageID)),
+		data,
+		strings.NewReader("</rpc>"),
+	)
+	return &rpc
+}
+
+// NewEditConfigStream can be used to create a `edit-config` message whose configuration is read from config.
+// Unlike NewEditConfig, the configuration cannot be validated beforehand.
+func NewEditConfigStream(datastoreType string, operationType string, config io.Reader) *RPCStream {
+	validateDatastore(datastoreType)
+	validDefaultOperation(operationType)
+
+	return NewRPCStream(io.MultiReader(
+		strings.NewReader(fmt.Sprintf(
+			"<edit-config><target><%s></%s></target><default-operation>%s</default-operation><config>",
+			datastoreType, datastoreType, operationType,
+		)),
+		config,
+		strings.NewReader("</config></edit-config>"),
+	))
+}
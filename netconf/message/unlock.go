@@ -30,3 +30,8 @@ func NewUnlock(datastoreType string) *Unlock {
 	rpc.MessageID = uuid()
 	return &rpc
 }
+
+// RequiredCapabilities returns the capability of the target datastore.
+func (rpc *Unlock) RequiredCapabilities() []string {
+	return rpc.Target.requiredCapabilities(false)
+}
@@ -30,3 +30,8 @@ func NewValidate(datastoreType string) *Validate {
 	rpc.MessageID = uuid()
 	return &rpc
 }
+
+// RequiredCapabilities returns the `:validate` capability, and the one of the source datastore.
+func (rpc *Validate) RequiredCapabilities() []string {
+	return append([]string{":validate"}, rpc.Source.requiredCapabilities(false)...)
+}
@@ -17,55 +17,149 @@ limitations under the License.
 package netconf
 
 import (
+	"context"
 	"encoding/xml"
-	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"time"
 
 	"github.com/openshift-telco/go-netconf-client/netconf/message"
 )
 
+// CallOption allows optional configuration of a single RPC.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	timeout  time.Duration
+	logAttrs []any
+}
+
+// WithCallTimeout bounds the RPC, in addition to the deadline of its context.
+func WithCallTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithCallLogAttrs adds key/value pairs to the messages logged about the RPC, e.g. a request ID.
+func WithCallLogAttrs(attrs ...any) CallOption {
+	return func(o *callOptions) {
+		o.logAttrs = append(o.logAttrs, attrs...)
+	}
+}
+
+// newCallOptions applies the options, and derives the context of the call from ctx.
+func newCallOptions(ctx context.Context, operation message.RPCMethod, options []CallOption) (context.Context, context.CancelFunc, *callOptions) {
+	o := &callOptions{}
+	for _, opt := range options {
+		opt(o)
+	}
+	o.logAttrs = append([]any{"messageID", operation.GetMessageID()}, o.logAttrs...)
+	if o.timeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, o.timeout)
+		return ctx, cancel, o
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, cancel, o
+}
+
 // CreateNotificationStream is a convenient method to create a notification stream registration.
 // TODO limitation - for now, we can only register one stream per session, because when a notification is received
 // there is no way to attribute it to a specific stream
 func (session *Session) CreateNotificationStream(
 	timeout int32, stopTime string, startTime string, stream string, callback Callback,
 ) error {
-	if session.IsNotificationStreamCreated {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	return session.CreateNotificationStreamContext(ctx, stopTime, startTime, stream, callback)
+}
+
+// CreateNotificationStreamContext is like CreateNotificationStream, bounding the creation of the stream by ctx.
+// The notification callback is unregistered when the stream could not be created.
+func (session *Session) CreateNotificationStreamContext(
+	ctx context.Context, stopTime string, startTime string, stream string, callback Callback, options ...CallOption,
+) error {
+	// Only one caller at a time may create the stream, the flag being reset when it could not be created.
+	if !session.notificationStream.CompareAndSwap(false, true) {
 		return fmt.Errorf(
 			"there is already an active notification stream subscription. " +
 				"A session can only support one notification stream at the time",
 		)
 	}
+	session.IsNotificationStreamCreated = true
 	session.Listener.Register(message.NetconfNotificationStreamHandler, callback)
 	sub := message.NewCreateSubscription(stopTime, startTime, stream)
-	rpc, err := session.SyncRPC(sub, timeout)
+	rpc, err := session.SyncRPCContext(ctx, sub, options...)
 	if err != nil {
+		session.Listener.Remove(message.NetconfNotificationStreamHandler)
+		session.IsNotificationStreamCreated = false
+		session.notificationStream.Store(false)
 		errMsg := "fail to create notification stream"
 		if rpc != nil && len(rpc.Errors) != 0 {
 			errMsg += fmt.Sprintf(" with errors: %s", rpc.Errors)
 		}
 		return fmt.Errorf("%s: %w", errMsg, err)
 	}
-	session.IsNotificationStreamCreated = true
 	return nil
 }
 
 // AsyncRPC is used to send an RPC method and receive the response asynchronously.
 func (session *Session) AsyncRPC(operation message.RPCMethod, callback Callback) error {
+	return session.AsyncRPCContext(context.Background(), operation, callback)
+}
 
-	// get XML payload
-	request, err := marshall(operation)
-	if err != nil {
+// AsyncRPCContext is used to send an RPC method and receive the response asynchronously. When ctx is done
+// before the reply is received, the callback is unregistered and will not be invoked. When the session is
+// closed before the reply is received, the callback is invoked with a termination event, see Event.Err.
+func (session *Session) AsyncRPCContext(ctx context.Context, operation message.RPCMethod, callback Callback, options ...CallOption) error {
+	ctx, cancel, o := newCallOptions(ctx, operation, options)
+	if err := ctx.Err(); err != nil {
+		cancel()
+		return fmt.Errorf("failed to execute request %s: %w", operation.GetMessageID(), err)
+	}
+	if err := session.Err(); err != nil {
+		cancel()
 		return err
 	}
+	release, err := session.window.acquire(ctx, session)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to execute request %s: %w", operation.GetMessageID(), err)
+	}
+	if session.rpcInterceptor != nil {
+		return session.asyncInvoke(ctx, cancel, release, operation, callback, o)
+	}
 
-	// register the listener for the message
-	session.Listener.Register(operation.GetMessageID(), callback)
+	// register the listener for the message, unregistering it when the context is done. The listener may
+	// be invoked by the reader goroutine as soon as it is registered, so stop must be set beforehand.
+	stop := context.AfterFunc(ctx, func() {
+		session.Listener.Remove(operation.GetMessageID())
+		release()
+		session.logger.WarnContext(ctx, "RPC abandoned before receiving its reply", append(o.logAttrs, "err", ctx.Err())...)
+	})
+	session.Listener.Register(operation.GetMessageID(), func(event Event) {
+		stop()
+		cancel()
+		release()
+		callback(event)
+	})
+	if err := ctx.Err(); err != nil {
+		// ctx was done before the listener was registered
+		stop()
+		cancel()
+		release()
+		session.Listener.Remove(operation.GetMessageID())
+		return fmt.Errorf("failed to execute request %s: %w", operation.GetMessageID(), err)
+	}
 
-	session.logger.Info("Sending RPC")
-	err = session.Transport.Send(request)
+	session.logger.InfoContext(ctx, "Sending RPC", o.logAttrs...)
+	err = session.send(operation)
 	if err != nil {
+		stop()
+		cancel()
+		release()
+		session.Listener.Remove(operation.GetMessageID())
 		return err
 	}
 
@@ -74,34 +168,146 @@ func (session *Session) AsyncRPC(operation message.RPCMethod, callback Callback)
 
 // SyncRPC is used to execute an RPC method and receive the response synchronously
 func (session *Session) SyncRPC(operation message.RPCMethod, timeout int32) (*message.RPCReply, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	return session.SyncRPCContext(ctx, operation)
+}
+
+// SyncRPCContext is used to execute an RPC method and receive the response synchronously. It returns once
+// the reply is received, or as soon as ctx is done, with an error wrapping ctx.Err(): context.Canceled or
+// context.DeadlineExceeded. A reply received afterwards is discarded. When the session is closed before the
+// reply is received, a SessionClosedError is returned.
+func (session *Session) SyncRPCContext(ctx context.Context, operation message.RPCMethod, options ...CallOption) (*message.RPCReply, error) {
+	return session.syncRPC(ctx, operation, true, options)
+}
+
+// syncRPC executes the RPC like SyncRPCContext. Unless windowed, the RPC is sent without waiting for a slot
+// when the RPCs in flight are bounded.
+func (session *Session) syncRPC(ctx context.Context, operation message.RPCMethod, windowed bool, options []CallOption) (*message.RPCReply, error) {
+	ctx, cancel, o := newCallOptions(ctx, operation, options)
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to execute request %s: %w", operation.GetMessageID(), err)
+	}
+	if err := session.Err(); err != nil {
+		return nil, err
+	}
+	if windowed {
+		release, err := session.window.acquire(ctx, session)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute request %s: %w", operation.GetMessageID(), err)
+		}
+		defer release()
+	}
 
-	// get XML payload
-	request, err := marshall(operation)
+	info, err := newRPCInfo(operation)
 	if err != nil {
 		return nil, err
 	}
+	return session.invoke(ctx, info, o, nil)
+}
+
+// roundTrip sends the request and waits for its reply. sent, when set, is invoked once the request is sent,
+// with the error met sending it.
+func (session *Session) roundTrip(ctx context.Context, info *RPCInfo, o *callOptions, sent func(error)) (*message.RPCReply, error) {
+	operation := info.Operation
 
 	// setup and register callback
-	reply := make(chan message.RPCReply, 1)
+	reply := make(chan Event, 1)
 	callback := func(event Event) {
-		reply <- *event.RPCReply()
-		session.logger.Info("Successfully executed RPC")
+		reply <- event
+		if event.Err() == nil {
+			session.logger.InfoContext(ctx, "Successfully executed RPC", o.logAttrs...)
+		}
 	}
 	session.Listener.Register(operation.GetMessageID(), callback)
 
 	// send rpc
-	session.logger.Info("Sending RPC")
-	err = session.Transport.Send(request)
+	session.logger.InfoContext(ctx, "Sending RPC", o.logAttrs...)
+	err := session.sendRequest(operation, info.Request)
+	if sent != nil {
+		sent(err)
+	}
 	if err != nil {
+		session.Listener.Remove(operation.GetMessageID())
 		return nil, err
 	}
 
 	select {
-	case res := <-reply:
-		return &res, nil
-	case <-time.After(time.Duration(timeout) * time.Second):
-		return nil, errors.New("timeout while executing request")
+	case event := <-reply:
+		if err := event.Err(); err != nil {
+			return nil, err
+		}
+		return event.RPCReply(), nil
+	case <-session.Done():
+		session.Listener.Remove(operation.GetMessageID())
+		return nil, session.Err()
+	case <-ctx.Done():
+		session.Listener.Remove(operation.GetMessageID())
+		session.logger.WarnContext(ctx, "RPC abandoned before receiving its reply", append(o.logAttrs, "err", ctx.Err())...)
+		return nil, fmt.Errorf("failed to execute request %s: %w", operation.GetMessageID(), ctx.Err())
+	}
+}
+
+// send writes the operation on the transport, unless rejected by the capability check. Operations implementing
+// io.Reader, such as message.RPCStream, are streamed rather than marshalled in memory.
+func (session *Session) send(operation message.RPCMethod) error {
+	return session.sendRequest(operation, nil)
+}
+
+// sendRequest is like send, sending request as the marshalled operation when it is set.
+func (session *Session) sendRequest(operation message.RPCMethod, request []byte) error {
+	if err := session.checkCapabilities(operation); err != nil {
+		return err
+	}
+	if request != nil || !isStreamed(operation) {
+		if request == nil {
+			var err error
+			if request, err = marshall(operation); err != nil {
+				return err
+			}
+		}
+		session.trace(TraceSent, request)
+		if err := session.Transport.Send(request); err != nil {
+			session.traceSendError(operation.GetMessageID(), err)
+			return err
+		}
+		session.window.sent.Add(1)
+		return nil
 	}
+
+	stream := io.MultiReader(strings.NewReader(xml.Header), operation.(io.Reader))
+	if st, ok := session.Transport.(StreamTransport); ok {
+		stream, traced := session.traceReader(TraceSent, stream)
+		err := st.SendReader(stream)
+		// Only the content read from the stream is traced, once it was handed to the transport.
+		traced()
+		if err != nil {
+			session.traceSendError(operation.GetMessageID(), err)
+			// Part of the message may already be written, leaving the framing out of sync with the server.
+			_ = session.closeWith(err)
+			return err
+		}
+		session.window.sent.Add(1)
+		return nil
+	}
+	b, err := io.ReadAll(stream)
+	if err != nil {
+		return err
+	}
+	session.trace(TraceSent, b)
+	if err := session.Transport.Send(b); err != nil {
+		session.traceSendError(operation.GetMessageID(), err)
+		return err
+	}
+	session.window.sent.Add(1)
+	return nil
+}
+
+// isStreamed reports whether the operation is streamed rather than marshalled in memory.
+func isStreamed(operation message.RPCMethod) bool {
+	_, ok := operation.(io.Reader)
+	return ok
 }
 
 func marshall(operation interface{}) ([]byte, error) {
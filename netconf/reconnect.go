@@ -0,0 +1,511 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultReconnectInitialBackoff = time.Second
+	defaultReconnectMaxBackoff     = time.Minute
+	defaultReconnectMultiplier     = 2
+	defaultReconnectJitter         = 0.2
+	defaultReconnectAttemptTimeout = 30 * time.Second
+)
+
+// ErrDisconnected indicates an RPC was sent while a ReconnectingSession was disconnected.
+var ErrDisconnected = errors.New("netconf: session disconnected")
+
+// ConnectionState is the state of the connection of a ReconnectingSession.
+type ConnectionState int
+
+const (
+	// StateConnected indicates a session is established, and RPCs are sent immediately.
+	StateConnected ConnectionState = iota
+	// StateDisconnected indicates the session was lost, or a connection attempt failed. A new
+	// attempt is made after a backoff delay.
+	StateDisconnected
+	// StateConnecting indicates a new session is being established.
+	StateConnecting
+	// StateClosed indicates the session was closed using Close, or reconnecting was given up. It is final.
+	StateClosed
+)
+
+// Names of connection states
+var connectionStateStrings = [...]string{
+	"connected", "disconnected", "connecting", "closed",
+}
+
+// String returns the name of the connection state
+func (s ConnectionState) String() string {
+	if s < 0 || int(s) >= len(connectionStateStrings) {
+		return fmt.Sprintf("ConnectionState(%d)", s)
+	}
+	return connectionStateStrings[s]
+}
+
+// StateChange is emitted every time the connection state of a ReconnectingSession changes.
+type StateChange struct {
+	State ConnectionState
+	// Err is the reason of a disconnection or of a failed attempt, or why reconnecting was given up.
+	Err error
+	// Attempt counts the connection attempts made since the session was lost.
+	Attempt int
+}
+
+// DisconnectedPolicy defines how a ReconnectingSession handles the RPCs sent while disconnected.
+type DisconnectedPolicy int
+
+const (
+	// FailFast fails the RPCs immediately with ErrDisconnected.
+	FailFast DisconnectedPolicy = iota
+	// Queue holds the RPCs until the session is reconnected, within the limits of their context.
+	Queue
+)
+
+// ReconnectConfig configures a ReconnectingSession. The zero value uses sensible defaults.
+type ReconnectConfig struct {
+	// InitialBackoff is the delay before the first connection attempt once the session is lost. Defaults to 1s.
+	InitialBackoff time.Duration
+	// MaxBackoff bounds the delay between two attempts. Defaults to 1m.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after every failed attempt. Defaults to 2.
+	Multiplier float64
+	// Jitter randomizes every delay by up to the provided fraction, so a fleet of sessions lost at once does not
+	// reconnect all at once. Defaults to 0.2, i.e. ±20%.
+	Jitter float64
+	// MaxAttempts is the number of failed attempts after which reconnecting is given up. Zero never gives up.
+	MaxAttempts int
+	// AttemptTimeout bounds every reconnection attempt, i.e. the dial and OnConnect. Defaults to 30s.
+	AttemptTimeout time.Duration
+	// Policy defines how the RPCs sent while disconnected are handled. Defaults to FailFast.
+	Policy DisconnectedPolicy
+	// OnStateChange, if set, is invoked on every state change. It must not block.
+	OnStateChange func(StateChange)
+	// OnConnect, if set, is invoked on every new session, before the subscriptions are re-established and
+	// before any RPC is sent on it, e.g. to acquire locks again. An error fails the connection attempt.
+	OnConnect func(ctx context.Context, session *Session) error
+}
+
+func (c *ReconnectConfig) setDefaults() {
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = defaultReconnectInitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultReconnectMaxBackoff
+	}
+	if c.Multiplier < 1 {
+		c.Multiplier = defaultReconnectMultiplier
+	}
+	if c.Jitter == 0 {
+		c.Jitter = defaultReconnectJitter
+	}
+	if c.AttemptTimeout <= 0 {
+		c.AttemptTimeout = defaultReconnectAttemptTimeout
+	}
+}
+
+// backoff returns the delay before the provided attempt, starting at 1.
+func (c *ReconnectConfig) backoff(attempt int) time.Duration {
+	d := float64(c.InitialBackoff)
+	for i := 1; i < attempt && d < float64(c.MaxBackoff); i++ {
+		d *= c.Multiplier
+	}
+	if d > float64(c.MaxBackoff) {
+		d = float64(c.MaxBackoff)
+	}
+	d += d * c.Jitter * (2*rand.Float64() - 1)
+	return time.Duration(d)
+}
+
+// SessionDialer establishes a new NETCONF session, including the hello exchange.
+type SessionDialer func(ctx context.Context) (*Session, error)
+
+// ReconnectingSession is a NETCONF session re-established automatically when lost, redialing with exponential
+// backoff and jitter. The notification subscriptions created through it are re-established on every new session.
+//
+// RPCs are not retried: an RPC pending when the session is lost fails with a SessionClosedError.
+type ReconnectingSession struct {
+	dial   SessionDialer
+	config ReconnectConfig
+
+	mu      sync.Mutex
+	session *Session
+	state   ConnectionState
+	// lastErr is the reason of the last disconnection
+	lastErr error
+	// changed is closed, and replaced, on every state change
+	changed       chan struct{}
+	subscriptions []*subscription
+	// subscribeMu serializes the creation of subscriptions with their replay on a new session
+	subscribeMu sync.Mutex
+	// err reports why the session was closed, once in StateClosed
+	err    *SessionClosedError
+	closed chan struct{}
+
+	// ctx is cancelled by Close, and supervised is closed once the supervising goroutine exits
+	ctx        context.Context
+	cancel     context.CancelFunc
+	supervised chan struct{}
+}
+
+// subscription is a notification subscription, established again on every new session.
+type subscription struct {
+	callback  Callback
+	establish func(ctx context.Context, session *Session) error
+}
+
+// NewReconnectingSession establishes a session using dial, returning an error if it fails. Afterwards, the
+// session is re-established using dial every time it is lost, until Close is called.
+func NewReconnectingSession(ctx context.Context, dial SessionDialer, config ReconnectConfig) (*ReconnectingSession, error) {
+	config.setDefaults()
+	r := &ReconnectingSession{
+		dial:       dial,
+		config:     config,
+		changed:    make(chan struct{}),
+		closed:     make(chan struct{}),
+		supervised: make(chan struct{}),
+	}
+	session, err := r.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.session = session
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	go r.supervise()
+	return r, nil
+}
+
+// NewReconnectingSessionFromSSHConfig establishes a ReconnectingSession connecting to the target using
+// ssh client configuration. See NewSessionFromSSHConfig. Every connection, including the SSH handshake,
+// is bounded by the context of the dial.
+func NewReconnectingSessionFromSSHConfig(
+	ctx context.Context, target string, config *ssh.ClientConfig, reconnect ReconnectConfig, options ...SessionOption,
+) (*ReconnectingSession, error) {
+	return NewReconnectingSession(ctx, func(ctx context.Context) (*Session, error) {
+		t, err := dialSSHContext(ctx, target, config)
+		if err != nil {
+			return nil, fmt.Errorf("DialSSH: %w", err)
+		}
+		return NewSession(t, options...)
+	}, reconnect)
+}
+
+// connect dials a new session, and prepares it for use.
+func (r *ReconnectingSession) connect(ctx context.Context) (*Session, error) {
+	session, err := r.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if r.config.OnConnect != nil {
+		if err := r.config.OnConnect(ctx, session); err != nil {
+			_ = session.Close()
+			return nil, err
+		}
+	}
+	return session, nil
+}
+
+// activate re-establishes the subscriptions on a new session, then makes it the current session.
+func (r *ReconnectingSession) activate(session *Session) error {
+	r.subscribeMu.Lock()
+	defer r.subscribeMu.Unlock()
+
+	r.mu.Lock()
+	subscriptions := r.subscriptions
+	r.mu.Unlock()
+	for _, s := range subscriptions {
+		if err := s.establish(r.ctx, session); err != nil {
+			_ = session.Close()
+			return fmt.Errorf("failed to re-establish subscription: %w", err)
+		}
+	}
+	if err := r.ctx.Err(); err != nil {
+		_ = session.Close()
+		return err
+	}
+	r.setState(StateChange{State: StateConnected}, session)
+	return nil
+}
+
+// supervise waits for the session to be lost, and re-establishes it.
+func (r *ReconnectingSession) supervise() {
+	defer close(r.supervised)
+	for {
+		session := r.Session()
+		select {
+		case <-session.Done():
+		case <-r.ctx.Done():
+			return
+		}
+		r.setState(StateChange{State: StateDisconnected, Err: session.Err()}, nil)
+
+		if err := r.reconnect(); err != nil {
+			if r.ctx.Err() == nil {
+				r.shutdown(err)
+			}
+			return
+		}
+	}
+}
+
+// reconnect establishes a new session, until it succeeds, the session is closed or reconnecting is given up.
+func (r *ReconnectingSession) reconnect() error {
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		if r.config.MaxAttempts > 0 && attempt > r.config.MaxAttempts {
+			return fmt.Errorf("gave up reconnecting after %d attempts: %w", r.config.MaxAttempts, lastErr)
+		}
+
+		timer := time.NewTimer(r.config.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-r.ctx.Done():
+			timer.Stop()
+			return r.ctx.Err()
+		}
+
+		r.setState(StateChange{State: StateConnecting, Attempt: attempt}, nil)
+		ctx, cancel := context.WithTimeout(r.ctx, r.config.AttemptTimeout)
+		session, err := r.connect(ctx)
+		cancel()
+		if err == nil {
+			err = r.activate(session)
+		}
+		if err == nil {
+			return nil
+		}
+		if r.ctx.Err() != nil {
+			return r.ctx.Err()
+		}
+		lastErr = err
+		r.setState(StateChange{State: StateDisconnected, Err: err, Attempt: attempt}, nil)
+	}
+}
+
+// setState records the new state, and the new session once connected, then notifies the change.
+func (r *ReconnectingSession) setState(change StateChange, session *Session) {
+	r.mu.Lock()
+	if r.err != nil {
+		r.mu.Unlock()
+		return
+	}
+	r.state = change.State
+	if session != nil {
+		r.session = session
+	}
+	if change.Err != nil {
+		r.lastErr = change.Err
+	}
+	close(r.changed)
+	r.changed = make(chan struct{})
+	r.mu.Unlock()
+
+	r.notify(change)
+}
+
+func (r *ReconnectingSession) notify(change StateChange) {
+	if r.config.OnStateChange != nil {
+		r.config.OnStateChange(change)
+	}
+}
+
+// shutdown moves to StateClosed, and delivers a termination event to the notification callbacks.
+func (r *ReconnectingSession) shutdown(cause error) {
+	r.mu.Lock()
+	if r.err != nil {
+		r.mu.Unlock()
+		return
+	}
+	r.err = &SessionClosedError{Cause: cause}
+	r.state = StateClosed
+	close(r.changed)
+	r.changed = make(chan struct{})
+	subscriptions := r.subscriptions
+	r.subscriptions = nil
+	r.mu.Unlock()
+
+	close(r.closed)
+	r.notify(StateChange{State: StateClosed, Err: cause})
+	for _, s := range subscriptions {
+		s.callback(&event{err: r.err})
+	}
+}
+
+// Close closes the session, and stops reconnecting. Queued RPCs fail with a SessionClosedError, and
+// the notification callbacks receive a termination event.
+func (r *ReconnectingSession) Close() error {
+	r.cancel()
+	<-r.supervised
+	err := r.Session().Close()
+	r.shutdown(nil)
+	return err
+}
+
+// Done returns a channel closed once the session is closed, either using Close or because reconnecting was given up.
+func (r *ReconnectingSession) Done() <-chan struct{} {
+	return r.closed
+}
+
+// Err returns nil until the session is closed. It then returns a SessionClosedError reporting why.
+func (r *ReconnectingSession) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		return nil
+	}
+	return r.err
+}
+
+// State returns the current connection state.
+func (r *ReconnectingSession) State() ConnectionState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+// Session returns the current session. It may have been lost, and not replaced yet.
+func (r *ReconnectingSession) Session() *Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.session
+}
+
+// current returns the session to send an RPC on, applying the DisconnectedPolicy.
+func (r *ReconnectingSession) current(ctx context.Context) (*Session, error) {
+	for {
+		r.mu.Lock()
+		session, state, lastErr, changed := r.session, r.state, r.lastErr, r.changed
+		closedErr := r.err
+		r.mu.Unlock()
+
+		switch {
+		case closedErr != nil:
+			return nil, closedErr
+		case state == StateConnected:
+			return session, nil
+		case r.config.Policy == FailFast && lastErr != nil:
+			return nil, fmt.Errorf("%w: %w", ErrDisconnected, lastErr)
+		case r.config.Policy == FailFast:
+			return nil, ErrDisconnected
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrDisconnected, ctx.Err())
+		}
+	}
+}
+
+// SyncRPC executes an RPC on the current session, see Session.SyncRPCContext.
+func (r *ReconnectingSession) SyncRPC(ctx context.Context, operation message.RPCMethod, options ...CallOption) (*message.RPCReply, error) {
+	session, err := r.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return session.SyncRPCContext(ctx, operation, options...)
+}
+
+// AsyncRPC sends an RPC on the current session, see Session.AsyncRPCContext.
+func (r *ReconnectingSession) AsyncRPC(ctx context.Context, operation message.RPCMethod, callback Callback, options ...CallOption) error {
+	session, err := r.current(ctx)
+	if err != nil {
+		return err
+	}
+	return session.AsyncRPCContext(ctx, operation, callback, options...)
+}
+
+// CreateNotificationStream creates a notification stream on the current session, see
+// Session.CreateNotificationStreamContext. The stream is created again on every new session, and the callback
+// only receives a termination event once the ReconnectingSession is closed.
+func (r *ReconnectingSession) CreateNotificationStream(
+	ctx context.Context, stopTime string, startTime string, stream string, callback Callback, options ...CallOption,
+) error {
+	return r.subscribe(ctx, callback, func(ctx context.Context, session *Session, callback Callback) error {
+		return session.CreateNotificationStreamContext(ctx, stopTime, startTime, stream, callback, options...)
+	})
+}
+
+// EstablishSubscription sends an `establish-subscription` RPC holding data, as defined in RFC8639, on the
+// current session, and registers callback for the notifications of the subscription. The subscription is
+// established again on every new session, and the callback only receives a termination event once the
+// ReconnectingSession is closed.
+func (r *ReconnectingSession) EstablishSubscription(ctx context.Context, data string, callback Callback, options ...CallOption) error {
+	return r.subscribe(ctx, callback, func(ctx context.Context, session *Session, callback Callback) error {
+		reply, err := session.SyncRPCContext(ctx, message.NewEstablishSubscription(data), options...)
+		if err != nil {
+			return err
+		}
+		if len(reply.Errors) != 0 {
+			return fmt.Errorf("failed to establish subscription with errors: %s", reply.Errors)
+		}
+		id := subscriptionID(reply)
+		if id == "" {
+			return errors.New("failed to establish subscription: no subscription id in reply")
+		}
+		session.Listener.Register(id, callback)
+		return nil
+	})
+}
+
+// subscribe establishes a subscription on the current session, and records it to be established again on
+// every new session. The termination events of the lost sessions are not forwarded to callback.
+func (r *ReconnectingSession) subscribe(
+	ctx context.Context, callback Callback, establish func(ctx context.Context, session *Session, callback Callback) error,
+) error {
+	forward := func(event Event) {
+		if event.Err() == nil {
+			callback(event)
+		}
+	}
+	s := &subscription{
+		callback: callback,
+		establish: func(ctx context.Context, session *Session) error {
+			return establish(ctx, session, forward)
+		},
+	}
+
+	for {
+		session, err := r.current(ctx)
+		if err != nil {
+			return err
+		}
+
+		// The subscription must be recorded before a new session replaces this one, to be replayed on it.
+		r.subscribeMu.Lock()
+		if session != r.Session() {
+			r.subscribeMu.Unlock()
+			continue
+		}
+		err = s.establish(ctx, session)
+		if err == nil {
+			r.mu.Lock()
+			r.subscriptions = append(r.subscriptions, s)
+			r.mu.Unlock()
+		}
+		r.subscribeMu.Unlock()
+		return err
+	}
+}
+
+// subscriptionIDRegex matches the identifier of a subscription in the reply to `establish-subscription`.
+var subscriptionIDRegex = regexp.MustCompile(`<(?:[\w.-]+:)?id(?:\s[^>]*)?>\s*([^<\s]+)\s*</`)
+
+func subscriptionID(reply *message.RPCReply) string {
+	if reply.SubscriptionID != "" {
+		return reply.SubscriptionID
+	}
+	if m := subscriptionIDRegex.FindStringSubmatch(reply.Data); m != nil {
+		return m[1]
+	}
+	return ""
+}
@@ -11,10 +11,14 @@ package netconf
 import (
 	"context"
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"regexp"
-	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/openshift-telco/go-netconf-client/netconf/message"
 )
@@ -38,19 +42,86 @@ type Logger interface {
 type SessionOption func(*Session)
 
 // Session represents a NETCONF sessions with a remote NETCONF server.
+// A Session is safe for concurrent use by multiple goroutines once created.
 type Session struct {
-	Transport                   Transport
-	SessionID                   int
-	Capabilities                []string
-	IsClosed                    bool
-	Listener                    *Dispatcher
+	Transport    Transport
+	SessionID    int
+	Capabilities []string
+	// Deprecated: IsClosed is not safe for concurrent use, use Closed.
+	IsClosed           bool
+	ClientCapabilities []string
+	BaseVersion        string
+	Listener           *Dispatcher
+	// Deprecated: IsNotificationStreamCreated is not safe for concurrent use, use NotificationStreamCreated.
 	IsNotificationStreamCreated bool
 	logger                      Logger
+	chunkSize                   int
+	helloTimeout                time.Duration
+	vendorCapabilities          []string
+	deferHello                  atomic.Bool
+	capabilityCheck             bool
+	serverCapabilities          *message.Capabilities
+	maxInFlight                 int
+	windowFailFast              bool
+	window                      *window
+
+	rpcInterceptors          []RPCInterceptor
+	rpcInterceptor           RPCInterceptor
+	notificationInterceptors []NotificationInterceptor
+	notificationInterceptor  NotificationInterceptor
+	keepalive                *KeepaliveConfig
+	tracer                   *tracer
+
+	// streams holds the callers of StreamRPC waiting for their reply, by message-id
+	streams   map[string]chan *replyStream
+	streamsMu sync.Mutex
+
+	// done is closed once the session is closed, and err reports why
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+	err       *SessionClosedError
+	// closing is set once close-session was sent, after which the server may hang up at any time
+	closing atomic.Bool
+
+	// notificationStream is set once a notification stream is created, or being created
+	notificationStream atomic.Bool
+}
+
+// ErrSessionClosed indicates the session is closed, either by the client or because the transport failed.
+var ErrSessionClosed = errors.New("netconf: session closed")
+
+// SessionClosedError is returned for the RPCs failed because the session is closed. It wraps both
+// ErrSessionClosed and the cause of the closure, if any, such as io.EOF or ErrPeerUnresponsive.
+type SessionClosedError struct {
+	// Cause is the transport failure which closed the session, or nil when it was closed using Close.
+	Cause error
+}
+
+// Error generates a string representation of the closure
+func (e *SessionClosedError) Error() string {
+	if e.Cause == nil {
+		return ErrSessionClosed.Error()
+	}
+	return fmt.Sprintf("%s: %s", ErrSessionClosed, e.Cause)
+}
+
+// Unwrap returns ErrSessionClosed and the cause of the closure, so errors.Is can be used to check for either.
+func (e *SessionClosedError) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{ErrSessionClosed}
+	}
+	return []error{ErrSessionClosed, e.Cause}
 }
 
 // NewSession creates a new NETCONF session using the provided transport layer.
+// It exchanges the hello messages with the server and negotiates the base version, after which the
+// session is ready to execute RPCs. The transport is closed if the hello exchange fails.
 func NewSession(t Transport, options ...SessionOption) (*Session, error) {
-	s := new(Session)
+	s := &Session{
+		ClientCapabilities: DefaultCapabilities,
+		helloTimeout:       defaultHelloTimeout,
+	}
 	for _, opt := range options {
 		opt(s)
 	}
@@ -60,21 +131,43 @@ func NewSession(t Transport, options ...SessionOption) (*Session, error) {
 	}
 
 	s.Transport = t
-
-	// Receive server Hello message
-	serverHello, err := s.ReceiveHello()
-	if err != nil {
-		return nil, err
+	if st, ok := t.(StreamTransport); ok && s.chunkSize > 0 {
+		st.SetChunkSize(s.chunkSize)
+	}
+	if kt, ok := t.(keepaliveTransport); ok && s.keepalive != nil {
+		kt.StartKeepalive(*s.keepalive)
 	}
-	s.SessionID = serverHello.SessionID
-	s.Capabilities = serverHello.Capabilities
 
 	s.Listener = &Dispatcher{}
 	s.Listener.init()
+	s.streams = make(map[string]chan *replyStream)
+	s.done = make(chan struct{})
+	s.window = newWindow(s.maxInFlight, s.windowFailFast)
+	s.rpcInterceptor = chainRPCInterceptors(s.rpcInterceptors)
+	s.notificationInterceptor = chainNotificationInterceptors(s.notificationInterceptors)
 
+	if s.deferHello.Load() {
+		return s, nil
+	}
+	if err := s.start(); err != nil {
+		return nil, err
+	}
 	return s, nil
 }
 
+// start exchanges the hello messages, then starts listening to incoming messages.
+// The session is closed if the hello exchange fails.
+func (session *Session) start() error {
+	if err := session.handshake(); err != nil {
+		_ = session.closeWith(err)
+		return err
+	}
+
+	// Once the hello-message exchange is done, start listening to incoming messages
+	session.listen()
+	return nil
+}
+
 // WithSessionLogger set the session logger provided in the session option.
 func WithSessionLogger(logger Logger) SessionOption {
 	return func(s *Session) {
@@ -82,117 +175,218 @@ func WithSessionLogger(logger Logger) SessionOption {
 	}
 }
 
-// SendHello send the initial message through NETCONF to advertise supported capability.
-func (session *Session) SendHello(hello *message.Hello) error {
-	val, err := xml.Marshal(hello)
-	if err != nil {
-		return err
+// WithChunkSize sets the maximum size of the chunks sent when using the NETCONF 1.1 chunked framing,
+// for transports supporting it. Messages larger than size are split in several chunks. Sizes above
+// 16 MiB are reduced to 16 MiB, as each chunk is buffered in memory before being sent.
+func WithChunkSize(size int) SessionOption {
+	return func(s *Session) {
+		s.chunkSize = limitChunkSize(size)
 	}
+}
 
-	header := []byte(xml.Header)
-	val = append(header, val...)
-	err = session.Transport.Send(val)
-
-	// Set Transport version after sending hello-message,
-	// so the hello-message is sent using netconf:1.0 framing
-	session.Transport.SetVersion("v1.0")
-	for _, capability := range session.Capabilities {
-		if strings.Contains(capability, message.NetconfVersion11) {
-			session.Transport.SetVersion("v1.1")
-			break
+// SendHello used to send the client hello once the session was created.
+//
+// When the session was created using WithDeferredHello, SendHello performs the whole hello exchange,
+// advertising the capabilities of hello if any, then starts listening to incoming messages. The session
+// is closed if the exchange fails. Otherwise, the hello messages were already exchanged: SendHello does
+// nothing, unless hello holds capabilities other than the advertised ones, which fails with ErrHelloExchanged.
+//
+// Deprecated: NewSession performs the whole hello exchange. Use WithClientCapabilities and
+// WithVendorCapabilities to customize the capabilities advertised by the client.
+func (session *Session) SendHello(hello *message.Hello) error {
+	if !session.deferHello.CompareAndSwap(true, false) {
+		if hello == nil || len(hello.Capabilities) == 0 {
+			return nil
+		}
+		requested := mergeCapabilities(hello.Capabilities, session.vendorCapabilities)
+		if !sameCapabilities(requested, session.ClientCapabilities) {
+			return fmt.Errorf("%w: cannot advertise %v, the client advertised %v",
+				ErrHelloExchanged, requested, session.ClientCapabilities)
 		}
+		return nil
 	}
-
-	// FIXME shouldn't be in SendHello function
-	// Once the hello-message exchange is done, start listening to incoming messages
-	session.listen()
-
-	return err
+	if hello != nil && len(hello.Capabilities) > 0 {
+		session.ClientCapabilities = hello.Capabilities
+	}
+	return session.start()
 }
 
 // ReceiveHello is the first message received when connecting to a NETCONF server.
 // It provides the supported capabilities of the server.
 func (session *Session) ReceiveHello() (*message.Hello, error) {
-	session.IsClosed = false
-
 	hello := new(message.Hello)
 
 	val, err := session.Transport.Receive()
 	if err != nil {
 		return hello, err
 	}
+	session.trace(TraceReceived, val)
 
 	err = xml.Unmarshal(val, hello)
 	return hello, err
 }
 
-// Close is used to close and end a session
+// Close is used to close and end a session. The pending RPCs fail with a SessionClosedError.
 func (session *Session) Close() error {
-	session.IsClosed = true
-	return session.Transport.Close()
+	return session.closeWith(nil)
+}
+
+// closeWith closes the session, recording cause as the reason. Every pending RPC fails, and every registered
+// callback, including the notification ones, receives a termination event. Only the first call has an effect.
+func (session *Session) closeWith(cause error) error {
+	session.closeOnce.Do(func() {
+		session.err = &SessionClosedError{Cause: cause}
+		session.IsClosed = true
+		close(session.done)
+		session.closeErr = session.Transport.Close()
+		session.Listener.terminate(session.err)
+	})
+	return session.closeErr
+}
+
+// Done returns a channel closed once the session is closed, either by the client or because the transport failed.
+func (session *Session) Done() <-chan struct{} {
+	return session.done
+}
+
+// Err returns nil while the session is open. Once it is closed, it returns a SessionClosedError reporting why.
+func (session *Session) Err() error {
+	select {
+	case <-session.done:
+		return session.err
+	default:
+		return nil
+	}
+}
+
+// NotificationStreamCreated reports whether a notification stream was created using CreateNotificationStream.
+func (session *Session) NotificationStreamCreated() bool {
+	return session.notificationStream.Load()
+}
+
+// Closed reports whether the session is closed. Unlike the IsClosed field, which is set once the session is
+// closed, it is safe for concurrent use, e.g. while the transport may fail.
+func (session *Session) Closed() bool {
+	return session.Err() != nil
 }
 
 // Listen starts a goroutine that listen to incoming messages and dispatch them as they are processed.
 func (session *Session) listen() {
 	go func() {
-		for ok := true; ok; ok = !session.IsClosed {
-			rawXML, err := session.Transport.Receive()
-			if err != nil {
-				// What should we do here?
-				continue
-			}
-			var rawReply = string(rawXML)
-			isRpcReply, err := regexp.MatchString(message.RpcReplyRegex, rawReply)
+		for {
+			err := session.receive()
 			if err != nil {
-				session.logger.Error("failed to match RPCReply",
-					"rawReply", rawReply,
-					"err", err,
-				)
-				continue
-			}
-
-			if isRpcReply {
-				rpcReply, err := message.NewRPCReply(rawXML)
-				if err != nil {
-					session.logger.Error("failed to marshall message into an RPCReply",
+				// The transport failed, or the framing can no longer be trusted: the session is over.
+				if session.closing.Load() {
+					_ = session.closeWith(nil)
+				} else if !session.Closed() {
+					session.logger.Error("closing session after failing to receive message",
+						"sessionID", session.SessionID,
 						"err", err,
 					)
-					continue
+					_ = session.closeWith(err)
 				}
-				session.Listener.Dispatch(rpcReply.MessageID, 0, rpcReply)
-				continue
+				break
 			}
+		}
+		session.logger.Info("exit receiving loop")
+	}()
+}
 
-			isNotification, err := regexp.MatchString(message.NotificationMessageRegex, rawReply)
-			if err != nil {
-				session.logger.Error("failed to match notification",
-					"rawReply", rawReply,
-					"err", err,
-				)
-				continue
-			}
-			if isNotification {
-				notification, err := message.NewNotification(rawXML)
-				if err != nil {
-					session.logger.Error("failed to marshall message into an Notification",
-						"err", err,
-					)
-					continue
-				}
-				// In case we are using straight create-subscription, there is no way to discern who is the owner
-				// of the received notification, hence we use a default handler.
-				if notification.GetSubscriptionID() == "" {
-					session.Listener.Dispatch(message.NetconfNotificationStreamHandler, 1, notification)
-				} else {
-					session.Listener.Dispatch(notification.GetSubscriptionID(), 1, notification)
-				}
-				continue
+// receive reads the next incoming message and dispatches it. When the transport supports it, the
+// message is streamed to the caller of StreamRPC instead of being read in memory.
+func (session *Session) receive() error {
+	st, ok := session.Transport.(StreamTransport)
+	if !ok {
+		rawXML, err := session.Transport.Receive()
+		if err != nil {
+			return err
+		}
+		session.trace(TraceReceived, rawXML)
+		session.dispatch(rawXML)
+		return nil
+	}
+
+	r, err := st.ReceiveReader()
+	if err != nil {
+		return err
+	}
+
+	r, traced := session.traceReader(TraceReceived, r)
+	root, body := peekRootElement(r)
+	if root != nil && root.Name.Local == "rpc-reply" {
+		if stream := session.deliverStream(attribute(root, "message-id"), body, traced); stream != nil {
+			// Wait for the caller to consume the reply before reading the next message, unless the session
+			// is closed meanwhile, as the caller may never do so.
+			select {
+			case <-stream.done:
+				return stream.err
+			case <-session.Done():
+				return session.Err()
 			}
+		}
+	}
+
+	rawXML, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	traced()
+	session.dispatch(rawXML)
+	return nil
+}
+
+// dispatch hands a received message to the callback registered for it.
+func (session *Session) dispatch(rawXML []byte) {
+	var rawReply = string(rawXML)
+	isRpcReply, err := regexp.MatchString(message.RpcReplyRegex, rawReply)
+	if err != nil {
+		session.logger.Error("failed to match RPCReply",
+			"rawReply", rawReply,
+			"err", err,
+		)
+		return
+	}
 
-			session.logger.Error("unknown received message",
-				"rawXML", rawXML,
+	if isRpcReply {
+		rpcReply, err := message.NewRPCReply(rawXML)
+		if err != nil {
+			session.logger.Error("failed to marshall message into an RPCReply",
+				"err", err,
 			)
+			return
 		}
-		session.logger.Info("exit receiving loop")
-	}()
+		session.Listener.Dispatch(rpcReply.MessageID, 0, rpcReply)
+		return
+	}
+
+	isNotification, err := regexp.MatchString(message.NotificationMessageRegex, rawReply)
+	if err != nil {
+		session.logger.Error("failed to match notification",
+			"rawReply", rawReply,
+			"err", err,
+		)
+		return
+	}
+	if isNotification {
+		notification, err := message.NewNotification(rawXML)
+		if err != nil {
+			session.logger.Error("failed to marshall message into an Notification",
+				"err", err,
+			)
+			return
+		}
+		// In case we are using straight create-subscription, there is no way to discern who is the owner
+		// of the received notification, hence we use a default handler.
+		if notification.GetSubscriptionID() == "" {
+			session.dispatchNotification(message.NetconfNotificationStreamHandler, notification)
+		} else {
+			session.dispatchNotification(notification.GetSubscriptionID(), notification)
+		}
+		return
+	}
+
+	session.logger.Error("unknown received message",
+		"rawXML", rawXML,
+	)
 }
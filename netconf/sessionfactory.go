@@ -2,6 +2,7 @@ package netconf
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"time"
 
@@ -52,3 +53,126 @@ func NewSessionFromSSHClient(ctx context.Context, client *ssh.Client, options ..
 
 	return s, nil
 }
+
+// NewSessionFromTLSConfig established a NETCONF session connecting to the target using tls client configuration.
+func NewSessionFromTLSConfig(target string, config *tls.Config, options ...SessionOption) (*Session, error) {
+	t, err := DialTLS(target, config)
+	if err != nil {
+		return nil, fmt.Errorf("DialTLS: %w", err)
+	}
+
+	s, err := NewSession(t, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NewSessionFromTLSConfigTimeout established a NETCONF session connecting to the target using tls client configuration with timeout.
+func NewSessionFromTLSConfigTimeout(ctx context.Context, target string, config *tls.Config, timeout time.Duration, options ...SessionOption) (*Session, error) {
+	t, err := DialTLSTimeout(target, config, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("DialTLSTimeout: %w", err)
+	}
+
+	s, err := NewSession(t, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NewSessionFromCommand established a NETCONF session over the stdin and stdout of a local command.
+// The command is killed when ctx is done.
+func NewSessionFromCommand(ctx context.Context, name string, args []string, options ...SessionOption) (*Session, error) {
+	t, err := DialCommandContext(ctx, name, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DialCommandContext: %w", err)
+	}
+
+	s, err := NewSession(t, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NewSessionFromTCP established a NETCONF session connecting to the target over plain TCP.
+func NewSessionFromTCP(target string, options ...SessionOption) (*Session, error) {
+	t, err := DialTCP(target)
+	if err != nil {
+		return nil, fmt.Errorf("DialTCP: %w", err)
+	}
+
+	s, err := NewSession(t, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NewSessionFromTCPTimeout established a NETCONF session connecting to the target over plain TCP with timeout.
+func NewSessionFromTCPTimeout(target string, timeout time.Duration, options ...SessionOption) (*Session, error) {
+	t, err := DialTCPTimeout(target, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("DialTCPTimeout: %w", err)
+	}
+
+	s, err := NewSession(t, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NewSessionFromUnix established a NETCONF session connecting to the UNIX domain socket at path.
+func NewSessionFromUnix(path string, options ...SessionOption) (*Session, error) {
+	t, err := DialUnix(path)
+	if err != nil {
+		return nil, fmt.Errorf("DialUnix: %w", err)
+	}
+
+	s, err := NewSession(t, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NewSessionFromSSHVia established a NETCONF session connecting to the target using ssh client configuration,
+// through a dialer and a chain of jump hosts. See TransportSSH.DialVia for arguments.
+func NewSessionFromSSHVia(dialer Dialer, target string, config *ssh.ClientConfig, jumpHosts []SSHJumpHost, options ...SessionOption) (*Session, error) {
+	t, err := DialSSHVia(dialer, target, config, jumpHosts...)
+	if err != nil {
+		return nil, fmt.Errorf("DialSSHVia: %w", err)
+	}
+
+	s, err := NewSession(t, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NewSessionFromSSHTransport established an additional NETCONF session over the SSH connection of an existing
+// transport, using a new channel. See TransportSSH.OpenChannel.
+func NewSessionFromSSHTransport(transport *TransportSSH, options ...SessionOption) (*Session, error) {
+	t, err := transport.OpenChannel()
+	if err != nil {
+		return nil, fmt.Errorf("OpenChannel: %w", err)
+	}
+
+	s, err := NewSession(t, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,263 @@
+package netconf
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+)
+
+// ElementCallback is invoked by ForEachElement for every matching element. The callback must consume
+// the element from the decoder, for instance using DecodeElement or Skip.
+type ElementCallback func(d *xml.Decoder, start xml.StartElement) error
+
+// StreamRPC is used to execute an RPC method and receive the response as a stream, so very large replies
+// such as a `get` of the full operational state can be processed with constant memory.
+//
+// The returned reader yields the raw rpc-reply document as it is read from the transport. It must be read
+// until io.EOF or closed: the session does not process any other message until then, or until the session is
+// closed. Callers should thus always close it, e.g. using defer. Use ForEachElement to decode the reply element
+// by element.
+//
+// When the transport does not support streaming, the reply is read in memory before being returned.
+func (session *Session) StreamRPC(operation message.RPCMethod, timeout int32) (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	return session.StreamRPCContext(ctx, operation)
+}
+
+// StreamRPCContext is like StreamRPC, waiting for the reply until ctx is done. It then returns an error wrapping
+// ctx.Err(): context.Canceled or context.DeadlineExceeded. Once returned, the reader is not bound by ctx.
+func (session *Session) StreamRPCContext(ctx context.Context, operation message.RPCMethod, options ...CallOption) (io.ReadCloser, error) {
+	if _, ok := session.Transport.(StreamTransport); !ok {
+		reply, err := session.SyncRPCContext(ctx, operation, options...)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(strings.NewReader(reply.RawReply)), nil
+	}
+
+	ctx, cancel, o := newCallOptions(ctx, operation, options)
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to execute request %s: %w", operation.GetMessageID(), err)
+	}
+	if err := session.Err(); err != nil {
+		return nil, err
+	}
+	release, err := session.window.acquire(ctx, session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request %s: %w", operation.GetMessageID(), err)
+	}
+	// The slot is released once the reply is received, before it is read.
+	defer release()
+
+	// setup and register the stream
+	reply := make(chan *replyStream, 1)
+	session.streamsMu.Lock()
+	session.streams[operation.GetMessageID()] = reply
+	session.streamsMu.Unlock()
+
+	// send rpc
+	session.logger.InfoContext(ctx, "Sending RPC", o.logAttrs...)
+	err = session.send(operation)
+	if err != nil {
+		session.cancelStream(operation.GetMessageID())
+		return nil, err
+	}
+
+	select {
+	case res := <-reply:
+		return res, nil
+	case <-session.Done():
+		session.cancelStream(operation.GetMessageID())
+		return nil, session.Err()
+	case <-ctx.Done():
+		session.cancelStream(operation.GetMessageID())
+		session.logger.WarnContext(ctx, "RPC abandoned before receiving its reply", append(o.logAttrs, "err", ctx.Err())...)
+		return nil, fmt.Errorf("failed to execute request %s: %w", operation.GetMessageID(), ctx.Err())
+	}
+}
+
+// deliverStream hands body to the caller of StreamRPC waiting for the reply messageID, if any.
+// traced is invoked once the reply was read, before the caller is released.
+func (session *Session) deliverStream(messageID string, body io.Reader, traced func()) *replyStream {
+	session.streamsMu.Lock()
+	defer session.streamsMu.Unlock()
+
+	reply, ok := session.streams[messageID]
+	if !ok {
+		return nil
+	}
+	delete(session.streams, messageID)
+
+	stream := &replyStream{Reader: body, done: make(chan struct{}), traced: traced}
+	reply <- stream
+	return stream
+}
+
+// cancelStream removes the registration of a stream, releasing the reply if it was already delivered.
+func (session *Session) cancelStream(messageID string) {
+	session.streamsMu.Lock()
+	reply := session.streams[messageID]
+	delete(session.streams, messageID)
+	session.streamsMu.Unlock()
+
+	select {
+	case stream := <-reply:
+		_ = stream.Close()
+	default:
+	}
+}
+
+// replyStream is a reply being streamed to the caller of StreamRPC.
+type replyStream struct {
+	io.Reader
+	once sync.Once
+	done chan struct{}
+	// traced records the reply in the trace, if enabled
+	traced func()
+	// err holds the transport error met while reading the reply, if any
+	err error
+}
+
+// Read reads the reply, releasing the session once it has been fully read.
+func (r *replyStream) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil {
+		r.release(err)
+	}
+	return n, err
+}
+
+// Close discards the remainder of the reply and releases the session.
+func (r *replyStream) Close() error {
+	_, err := io.Copy(io.Discard, r.Reader)
+	r.release(err)
+	return nil
+}
+
+func (r *replyStream) release(err error) {
+	r.once.Do(func() {
+		if err != io.EOF {
+			r.err = err
+		}
+		r.traced()
+		close(r.done)
+	})
+}
+
+// peekRootElement reads the beginning of the message until its root element, and returns it together
+// with a reader replaying the whole message. The root element is nil if the message is not valid XML.
+func peekRootElement(r io.Reader) (*xml.StartElement, io.Reader) {
+	peeked := &recordingReader{r: bufio.NewReader(r)}
+	body := func() io.Reader {
+		return io.MultiReader(bytes.NewReader(peeked.buf.Bytes()), peeked.r)
+	}
+
+	d := xml.NewDecoder(peeked)
+	for {
+		token, err := d.RawToken()
+		if err != nil {
+			return nil, body()
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return &start, body()
+		}
+	}
+}
+
+// recordingReader keeps a copy of the bytes read. As it implements io.ByteReader, xml.Decoder does not read ahead.
+type recordingReader struct {
+	r   *bufio.Reader
+	buf bytes.Buffer
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf.Write(p[:n])
+	return n, err
+}
+
+func (r *recordingReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.buf.WriteByte(b)
+	}
+	return b, err
+}
+
+// attribute returns the value of the attribute with the provided local name.
+func attribute(start *xml.StartElement, name string) string {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// ForEachElement decodes the XML document read from r, such as a reply obtained with StreamRPC, and invokes
+// callback for every element matching path. path is a list of element local names separated by "/", and is
+// matched against the end of the element hierarchy, e.g. "interfaces/interface" matches every interface entry
+// of the interfaces container, wherever the container is in the document.
+//
+// When the document is an rpc-reply holding an rpc-error, the error is returned as a *message.RPCError.
+// Decoding stops at the first error returned by callback.
+func ForEachElement(r io.Reader, path string, callback ElementCallback) error {
+	match := strings.Split(strings.Trim(path, "/"), "/")
+	var stack []string
+
+	d := xml.NewDecoder(r)
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(stack) == 1 && stack[0] == "rpc-reply" && t.Name.Local == "rpc-error" {
+				rpcErr := &message.RPCError{}
+				if err := d.DecodeElement(rpcErr, &t); err != nil {
+					return err
+				}
+				return rpcErr
+			}
+
+			stack = append(stack, t.Name.Local)
+			if !hasSuffix(stack, match) {
+				continue
+			}
+			if err := callback(d, t); err != nil {
+				return err
+			}
+			stack = stack[:len(stack)-1]
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+func hasSuffix(stack []string, suffix []string) bool {
+	if len(stack) < len(suffix) {
+		return false
+	}
+	offset := len(stack) - len(suffix)
+	for i, name := range suffix {
+		if stack[offset+i] != name {
+			return false
+		}
+	}
+	return true
+}
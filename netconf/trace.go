@@ -0,0 +1,211 @@
+package netconf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+)
+
+// TraceDirection tells whether a traced message was sent or received by the client.
+type TraceDirection string
+
+const (
+	TraceSent     TraceDirection = "sent"
+	TraceReceived TraceDirection = "received"
+)
+
+// TraceEntry is a message recorded in a trace, see WithTrace.
+type TraceEntry struct {
+	Time      time.Time      `json:"time"`
+	Direction TraceDirection `json:"direction"`
+	// Version is the framing used for the message, either v1.0 (end-of-message marker) or v1.1 (chunked).
+	Version string `json:"version"`
+	// MessageID is the message-id of the rpc or rpc-reply, if any.
+	MessageID string `json:"message-id,omitempty"`
+	// Data is the message, without the framing, after redaction.
+	Data string `json:"data"`
+	// Err is set when the message previously recorded with the same message-id could not be sent, in
+	// which case Data is empty.
+	Err string `json:"error,omitempty"`
+}
+
+// RedactionRule rewrites a traced message to remove sensitive content, see RedactElements and RedactRegexp.
+type RedactionRule func(data []byte) []byte
+
+// DefaultRedactionRules redacts the content of the elements commonly holding secrets.
+var DefaultRedactionRules = []RedactionRule{
+	RedactElements("password", "secret", "passphrase", "pre-shared-key", "private-key", "community"),
+}
+
+// redacted replaces the content removed by the redaction rules.
+const redacted = "*****"
+
+// RedactElements returns a rule replacing the content of the elements with the provided local names,
+// whatever their namespace prefix.
+func RedactElements(names ...string) RedactionRule {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	alternatives := strings.Join(quoted, "|")
+	re := regexp.MustCompile(`(?s)(<(?:[\w.-]+:)?(?:` + alternatives + `)(?:\s[^>]*)?>)(.*?)(</(?:[\w.-]+:)?(?:` + alternatives + `)\s*>)`)
+	return func(data []byte) []byte {
+		return re.ReplaceAll(data, []byte("${1}"+redacted+"${3}"))
+	}
+}
+
+// RedactRegexp returns a rule replacing every match of re with replacement, which may reference the
+// submatches of re as in regexp.Regexp.Expand.
+func RedactRegexp(re *regexp.Regexp, replacement string) RedactionRule {
+	return func(data []byte) []byte {
+		return re.ReplaceAll(data, []byte(replacement))
+	}
+}
+
+// WithTrace records every message sent or received by the session to w, including the hello messages,
+// as JSON lines of TraceEntry. The redaction rules are applied, in order, to every message before it is
+// recorded; see DefaultRedactionRules. Use TraceDecoder or WriteTranscript to read the trace.
+//
+// The messages are recorded as handed to, or returned by, the transport: the framing, i.e. the end-of-message
+// markers or the chunk headers, is not part of the trace, only the framing version is. A message sent is recorded
+// before being written, to keep the trace ordered with its reply; when it cannot be sent, an entry reporting
+// the error follows.
+//
+// Messages streamed using StreamRPC or message.RPCStream are held in memory to be recorded, and are recorded
+// once written.
+// Failing to write the trace does not affect the session.
+func WithTrace(w io.Writer, rules ...RedactionRule) SessionOption {
+	return func(s *Session) {
+		s.tracer = &tracer{w: w, rules: rules}
+	}
+}
+
+// tracer records the messages of a session.
+type tracer struct {
+	mu    sync.Mutex
+	w     io.Writer
+	rules []RedactionRule
+}
+
+// messageIDRegex matches the message-id attribute of the root rpc or rpc-reply element.
+var messageIDRegex = regexp.MustCompile(`<(?:[\w.-]+:)?rpc(?:-reply)?\s[^>]*?\bmessage-id\s*=\s*["']([^"']*)["']`)
+
+func (t *tracer) record(entry TraceEntry, data []byte) error {
+	entry.Time = time.Now()
+	if m := messageIDRegex.FindSubmatch(data); m != nil {
+		entry.MessageID = string(m[1])
+	}
+	for _, rule := range t.rules {
+		data = rule(data)
+	}
+	entry.Data = string(data)
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err = t.w.Write(append(b, '\n'))
+	return err
+}
+
+// trace records a message when tracing is enabled.
+func (session *Session) trace(direction TraceDirection, data []byte) {
+	if session.tracer == nil {
+		return
+	}
+	session.record(TraceEntry{Direction: direction}, data)
+}
+
+// traceSendError records that the message with the provided message-id, traced beforehand, could not be sent,
+// when tracing is enabled.
+func (session *Session) traceSendError(messageID string, err error) {
+	if session.tracer == nil {
+		return
+	}
+	session.record(TraceEntry{Direction: TraceSent, MessageID: messageID, Err: err.Error()}, nil)
+}
+
+func (session *Session) record(entry TraceEntry, data []byte) {
+	entry.Version = "v1.0"
+	if session.BaseVersion == message.NetconfVersion11 {
+		entry.Version = "v1.1"
+	}
+	if err := session.tracer.record(entry, data); err != nil {
+		session.logger.Warn("failed to record trace",
+			"sessionID", session.SessionID,
+			"err", err,
+		)
+	}
+}
+
+// traceReader records the content read from r once it has been fully read, when tracing is enabled.
+func (session *Session) traceReader(direction TraceDirection, r io.Reader) (io.Reader, func()) {
+	if session.tracer == nil {
+		return r, func() {}
+	}
+	var buf bytes.Buffer
+	return io.TeeReader(r, &buf), func() {
+		session.trace(direction, buf.Bytes())
+	}
+}
+
+// TraceDecoder reads the entries of a trace recorded using WithTrace.
+type TraceDecoder struct {
+	decoder *json.Decoder
+}
+
+// NewTraceDecoder returns a decoder reading the trace from r.
+func NewTraceDecoder(r io.Reader) *TraceDecoder {
+	return &TraceDecoder{decoder: json.NewDecoder(r)}
+}
+
+// Decode returns the next entry of the trace, or io.EOF at the end of the trace.
+func (d *TraceDecoder) Decode() (*TraceEntry, error) {
+	entry := &TraceEntry{}
+	if err := d.decoder.Decode(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// WriteTranscript renders the trace read from r as a readable transcript written to w, such as:
+//
+//	2006-01-02T15:04:05.000Z >>> sent v1.1 message-id=1
+//	<rpc message-id="1" xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><commit/></rpc>
+func WriteTranscript(w io.Writer, r io.Reader) error {
+	out := bufio.NewWriter(w)
+	d := NewTraceDecoder(r)
+	for {
+		entry, err := d.Decode()
+		if err == io.EOF {
+			return out.Flush()
+		}
+		if err != nil {
+			return err
+		}
+
+		arrow := ">>>"
+		if entry.Direction == TraceReceived {
+			arrow = "<<<"
+		}
+		_, _ = fmt.Fprintf(out, "%s %s %s %s", entry.Time.UTC().Format("2006-01-02T15:04:05.000Z"), arrow, entry.Direction, entry.Version)
+		if entry.MessageID != "" {
+			_, _ = fmt.Fprintf(out, " message-id=%s", entry.MessageID)
+		}
+		if entry.Err != "" {
+			_, _ = fmt.Fprintf(out, "\nfailed to send: %s\n\n", entry.Err)
+			continue
+		}
+		_, _ = fmt.Fprintf(out, "\n%s\n\n", bytes.TrimSpace([]byte(entry.Data)))
+	}
+}
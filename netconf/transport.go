@@ -16,6 +16,7 @@ import (
 	"io"
 	"regexp"
 	"strconv"
+	"sync"
 )
 
 const (
@@ -25,7 +26,8 @@ const (
 )
 
 // Transport interface defines what characteristics make up a NETCONF transport
-// layer object.
+// layer object. Send may be invoked concurrently by the RPCs of a session, so the
+// implementations must not interleave the messages sent.
 type Transport interface {
 	Send([]byte) error
 	Receive() ([]byte, error)
@@ -33,57 +35,133 @@ type Transport interface {
 	SetVersion(version string)
 }
 
+// StreamTransport is implemented by transports able to stream messages, instead of holding them in memory.
+type StreamTransport interface {
+	Transport
+	// ReceiveReader waits for the next message and returns a reader over its content, without the framing.
+	// The reader must be read until io.EOF before the next message can be received.
+	ReceiveReader() (io.Reader, error)
+	// SendReader sends a message whose content is read from the provided reader, adding the framing.
+	SendReader(io.Reader) error
+	// SetChunkSize sets the maximum size of the chunks sent when using the NETCONF 1.1 chunked framing.
+	SetChunkSize(size int)
+}
+
 type transportBasicIO struct {
 	io.ReadWriteCloser
+	// mu guards version and chunkSize, which may be set while messages are sent and received
+	mu sync.Mutex
+	// writeMu serializes the messages sent, so concurrent senders do not interleave their frames
+	writeMu sync.Mutex
 	//new add
 	version string
+	// reader buffers the incoming bytes, so data read ahead while decoding
+	// the framing of a message is kept for the next one
+	reader *bufio.Reader
+	// chunkSize is the maximum size of the chunks sent with the chunked framing
+	chunkSize int
 }
 
 func (t *transportBasicIO) SetVersion(version string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.version = version
 }
 
+// framing returns the version of the framing, and the configured chunk size.
+func (t *transportBasicIO) framing() (string, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.version, t.chunkSize
+}
+
+// SetChunkSize sets the maximum size of the chunks sent when using the NETCONF 1.1 chunked framing.
+// When not set, Send writes the whole message as a single chunk, and SendReader uses chunks of
+// DefaultChunkSize bytes. Chunks are never larger than 16 MiB, as each one is buffered in memory.
+func (t *transportBasicIO) SetChunkSize(size int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.chunkSize = limitChunkSize(size)
+}
+
 // Send a well formatted NETCONF rpc message as a slice of bytes adding on the
 // necessary framing messages.
 func (t *transportBasicIO) Send(data []byte) error {
-	var separator []byte
-	var dataInfo []byte
-	if t.version == "v1.1" {
-		separator = append(separator, []byte(msgSeparatorV11)...)
-	} else {
-		separator = append(separator, []byte(msgSeparator)...)
+	_, size := t.framing()
+	if size <= 0 {
+		size = len(data)
+	}
+	return t.send(bytes.NewReader(data), size)
+}
+
+// SendReader sends a NETCONF message read from r adding on the necessary framing messages.
+// The message is never fully held in memory: with the chunked framing, it is split in chunks
+// of at most the configured chunk size.
+func (t *transportBasicIO) SendReader(r io.Reader) error {
+	_, size := t.framing()
+	if size <= 0 {
+		size = DefaultChunkSize
 	}
+	return t.send(r, size)
+}
+
+func (t *transportBasicIO) send(r io.Reader, chunkSize int) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
 
-	if t.version == "v1.1" {
-		header := fmt.Sprintf("\n#%d\n", len(string(data)))
-		dataInfo = append(dataInfo, header...)
+	if version, _ := t.framing(); version == "v1.1" {
+		return writeChunked(t.ReadWriteCloser, r, chunkSize)
 	}
-	dataInfo = append(dataInfo, data...)
-	dataInfo = append(dataInfo, separator...)
-	_, err := t.Write(dataInfo)
 
+	if _, err := io.Copy(t.ReadWriteCloser, r); err != nil {
+		return err
+	}
+	_, err := t.Write([]byte(msgSeparator))
 	return err
 }
 
+// Receive reads the next NETCONF message, removing its framing.
+// When the connection is closed between two messages, io.EOF is returned.
 func (t *transportBasicIO) Receive() ([]byte, error) {
-	var separator []byte
-	if t.version == "v1.1" {
-		separator = append(separator, []byte(msgSeparatorV11)...)
-		// NOTES: This is not clever at all
-		// you are reading the O-RU response content once with WaitForBytes, and then you read it again to get rid of
-		// the #<chunk-size> pieces. Using Chunked would be enough, but if you pass in the t.ReadWriteCloser to the
-		// splitChunked function it gets stuck when doing the Read of the last piece of the NETCONF message.
-		// This will need to be addressed in the future.
-		// Also, splitChunked function comes from the andaru/netconf library, with just a slight modification to make it
-		// work.
-		b, err := t.WaitForBytes(separator)
-		if err != nil {
-			return nil, err
-		}
-		return t.Chunked(b)
+	r := t.messageReader()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !r.complete() {
+		return nil, io.EOF
+	}
+	return b, nil
+}
+
+// ReceiveReader waits for the next message and returns a reader decoding it as it is read.
+// When the connection is closed between two messages, io.EOF is returned.
+func (t *transportBasicIO) ReceiveReader() (io.Reader, error) {
+	if _, err := t.bufferedReader().Peek(1); err != nil {
+		return nil, err
+	}
+	return t.messageReader(), nil
+}
+
+// messageReader returns a reader decoding the next message using the framing of the current version.
+func (t *transportBasicIO) messageReader() messageReader {
+	if version, _ := t.framing(); version == "v1.1" {
+		return newChunkedReader(t.bufferedReader())
+	}
+	return newEOMReader(t.bufferedReader())
+}
+
+func (t *transportBasicIO) bufferedReader() *bufio.Reader {
+	if t.reader == nil {
+		t.reader = bufio.NewReader(t.ReadWriteCloser)
 	}
-	separator = append(separator, []byte(msgSeparator)...)
-	return t.WaitForBytes(separator)
+	return t.reader
+}
+
+// Read reads from the buffered incoming bytes, so raw reads and message
+// decoding can be mixed without losing data.
+func (t *transportBasicIO) Read(b []byte) (int, error) {
+	return t.bufferedReader().Read(b)
 }
 
 func (t *transportBasicIO) Writeln(b []byte) (int, error) {
@@ -198,18 +276,9 @@ func SplitChunked(endOfMessage func()) bufio.SplitFunc {
 // ErrBadChunk indicates a chunked framing protocol error occurred
 var ErrBadChunk = errors.New("bad chunk")
 
+// Chunked decodes a NETCONF message using the chunked framing held in b.
 func (t *transportBasicIO) Chunked(b []byte) ([]byte, error) {
-	rdr := bytes.NewReader(b)
-	scanner := bufio.NewScanner(rdr)
-	bsize := 16
-	scanner.Buffer(make([]byte, bsize), bsize*2)
-
-	scanner.Split(SplitChunked(nil))
-	var got []byte
-	for scanner.Scan() {
-		got = append(got, scanner.Bytes()...)
-	}
-	return got, nil
+	return io.ReadAll(newChunkedReader(bufio.NewReader(bytes.NewReader(b))))
 }
 
 func (t *transportBasicIO) WaitForFunc(f func([]byte) (int, error)) ([]byte, error) {
@@ -0,0 +1,225 @@
+package netconf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// commandExitTimeout is how long a command has to exit once its stdin is closed, before being killed.
+	commandExitTimeout = 5 * time.Second
+	// commandStderrSize is the amount of stderr output kept for diagnostics.
+	commandStderrSize = 64 * 1024
+)
+
+// CommandExitError reports a command used as transport that exited unsuccessfully.
+type CommandExitError struct {
+	// Err is the error returned when waiting for the command, usually an *exec.ExitError.
+	Err error
+	// Stderr holds the end of what the command wrote on its standard error.
+	Stderr string
+}
+
+// Error generates a string representation of the command failure, including its stderr.
+func (e *CommandExitError) Error() string {
+	stderr := strings.TrimSpace(e.Stderr)
+	if stderr == "" {
+		return fmt.Sprintf("command failed: %v", e.Err)
+	}
+	return fmt.Sprintf("command failed: %v: %s", e.Err, stderr)
+}
+
+// Unwrap returns the error returned when waiting for the command.
+func (e *CommandExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit code of the command, or -1 if it was terminated by a signal.
+func (e *CommandExitError) ExitCode() int {
+	if exitErr, ok := e.Err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// TransportCommand speaks NETCONF over the stdin and stdout of a local command, such as
+// `ssh -s <host> netconf`, `docker exec -i <container> netopeer2-cli` or a netconf-subsys binary.
+type TransportCommand struct {
+	transportBasicIO
+	cmd    *exec.Cmd
+	stdout *os.File
+	stderr *stderrBuffer
+	exited chan struct{}
+	err    error
+	once   sync.Once
+}
+
+// DialCommand starts the named command with the provided arguments, and creates a new command Transport.
+func DialCommand(name string, args ...string) (*TransportCommand, error) {
+	return NewTransportCommand(exec.Command(name, args...))
+}
+
+// DialCommandContext is like DialCommand, but the command is killed when ctx is done.
+func DialCommandContext(ctx context.Context, name string, args ...string) (*TransportCommand, error) {
+	return NewTransportCommand(exec.CommandContext(ctx, name, args...))
+}
+
+// NewTransportCommand starts the provided command and creates a new command Transport over its stdin and stdout.
+// The command must not have Stdin, Stdout or Stderr set: its stderr is captured, see Stderr.
+func NewTransportCommand(cmd *exec.Cmd) (*TransportCommand, error) {
+	if cmd.Stdin != nil || cmd.Stdout != nil || cmd.Stderr != nil {
+		return nil, fmt.Errorf("exec: Stdin, Stdout and Stderr must not be set")
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	// The stdout pipe is managed here, as exec.Cmd.StdoutPipe would be closed as soon as the
+	// command exits, possibly before the last messages are read.
+	stdout, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	t := &TransportCommand{
+		cmd:    cmd,
+		stdout: stdout,
+		stderr: &stderrBuffer{},
+		exited: make(chan struct{}),
+	}
+	cmd.Stdout = w
+	cmd.Stderr = t.stderr
+	if cmd.WaitDelay == 0 {
+		cmd.WaitDelay = commandExitTimeout
+	}
+
+	err = cmd.Start()
+	_ = w.Close()
+	if err != nil {
+		_ = stdout.Close()
+		return nil, err
+	}
+
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			t.err = &CommandExitError{Err: err, Stderr: t.stderr.String()}
+		}
+		close(t.exited)
+	}()
+
+	t.ReadWriteCloser = NewReadWriteCloser(&commandReader{t}, &commandWriter{stdin, t})
+	return t, nil
+}
+
+// Stderr returns the end of what the command wrote on its standard error so far.
+func (t *TransportCommand) Stderr() string {
+	return t.stderr.String()
+}
+
+// Exited returns a channel that is closed once the command has exited.
+func (t *TransportCommand) Exited() <-chan struct{} {
+	return t.exited
+}
+
+// Err returns a *CommandExitError if the command exited unsuccessfully, or nil if it exited successfully
+// or is still running.
+func (t *TransportCommand) Err() error {
+	select {
+	case <-t.exited:
+		return t.err
+	default:
+		return nil
+	}
+}
+
+// Close closes the stdin of the command and waits for it to exit, killing it if it does not exit in time.
+// A *CommandExitError is returned if the command exited unsuccessfully.
+func (t *TransportCommand) Close() error {
+	// If TransportCommand is nil ignore closing the command
+	if t == nil {
+		return nil
+	}
+
+	t.once.Do(func() {
+		_ = t.ReadWriteCloser.Close()
+		select {
+		case <-t.exited:
+		case <-time.After(commandExitTimeout):
+			_ = t.cmd.Process.Kill()
+			<-t.exited
+		}
+		_ = t.stdout.Close()
+	})
+	return t.err
+}
+
+// wait waits a bounded amount of time for the command to exit, and returns its exit error, if any.
+func (t *TransportCommand) wait() error {
+	select {
+	case <-t.exited:
+		return t.err
+	case <-time.After(commandExitTimeout):
+		return nil
+	}
+}
+
+// commandReader reads the stdout of the command. Once the command closed its stdout, the exit
+// error of the command is returned rather than io.EOF, so the failure reason is surfaced.
+type commandReader struct {
+	t *TransportCommand
+}
+
+func (r *commandReader) Read(p []byte) (int, error) {
+	n, err := r.t.stdout.Read(p)
+	if err == io.EOF {
+		if exitErr := r.t.wait(); exitErr != nil {
+			return n, exitErr
+		}
+	}
+	return n, err
+}
+
+// commandWriter writes to the stdin of the command. When the write fails because the command
+// exited, its exit error is returned instead.
+type commandWriter struct {
+	io.WriteCloser
+	t *TransportCommand
+}
+
+func (w *commandWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	if err != nil {
+		if exitErr := w.t.wait(); exitErr != nil {
+			return n, exitErr
+		}
+	}
+	return n, err
+}
+
+// stderrBuffer keeps the last commandStderrSize bytes written to it.
+type stderrBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *stderrBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > commandStderrSize {
+		b.buf = b.buf[len(b.buf)-commandStderrSize:]
+	}
+	return len(p), nil
+}
+
+func (b *stderrBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,65 @@
+package netconf
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// TransportConn maintains the information necessary to communicate with the remote device
+// directly over a stream connection, without any secure transport. It is meant for co-located
+// daemons, such as netopeer2 listening on a UNIX socket, or simulators listening on plain TCP.
+type TransportConn struct {
+	transportBasicIO
+	conn net.Conn
+}
+
+// Close closes the connection if it exists.
+func (t *TransportConn) Close() error {
+	// If TransportConn is nil ignore closing the connection
+	if t == nil {
+		return nil
+	}
+
+	if t.conn != nil {
+		return t.conn.Close()
+	}
+	return fmt.Errorf("no connection to close")
+}
+
+// Conn returns the underlying connection.
+func (t *TransportConn) Conn() net.Conn {
+	return t.conn
+}
+
+// NewTransportConn creates a new Transport over an established connection.
+func NewTransportConn(conn net.Conn) *TransportConn {
+	t := &TransportConn{conn: conn}
+	t.ReadWriteCloser = conn
+	return t
+}
+
+// DialTCP creates a new plain TCP Transport. target must specify a port, with the format <host>:<port>,
+// as there is no default port for NETCONF over plain TCP.
+func DialTCP(target string) (*TransportConn, error) {
+	return DialTCPTimeout(target, 0)
+}
+
+// DialTCPTimeout creates a new plain TCP Transport with timeout.
+// See DialTCP for arguments. The timeout value is used for connection establishment.
+func DialTCPTimeout(target string, timeout time.Duration) (*TransportConn, error) {
+	conn, err := net.DialTimeout("tcp", target, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return NewTransportConn(conn), nil
+}
+
+// DialUnix creates a new Transport connected to the UNIX domain socket at path.
+func DialUnix(path string) (*TransportConn, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return NewTransportConn(conn), nil
+}
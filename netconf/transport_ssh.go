@@ -9,12 +9,14 @@
 package netconf
 
 import (
-	"crypto/x509"
-	"encoding/pem"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/ssh"
@@ -26,6 +28,8 @@ const (
 	sshDefaultPort = 830
 	// sshNetconfSubsystem sets the SSH subsystem to NETCONF
 	sshNetconfSubsystem = "netconf"
+	// sshJumpHostDefaultPort is the default port used when communicating with jump hosts
+	sshJumpHostDefaultPort = 22
 )
 
 // TransportSSH maintains the information necessary to communicate with the
@@ -34,15 +38,112 @@ type TransportSSH struct {
 	transportBasicIO
 	sshClient  *ssh.Client
 	sshSession *ssh.Session
+	// jumpClients holds the connections to the jump hosts used to reach the target, in dialing order
+	jumpClients []*ssh.Client
+	// conn holds the state shared by the transports using sshClient, see OpenChannel
+	conn      *sshConn
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // Close closes an existing SSH session and socket if they exist.
+// When other transports were opened on the same SSH connection using OpenChannel, only the
+// SSH session of this transport is closed, and the connection is closed along with the last one.
 func (t *TransportSSH) Close() error {
 	// If TransportSSH is nil ignore closing ssh session
 	if t == nil {
 		return nil
 	}
 
+	t.closeOnce.Do(func() {
+		t.closeErr = t.close()
+	})
+	return t.closeErr
+}
+
+func (t *TransportSSH) close() error {
+	if t.conn != nil && !t.conn.release() {
+		// Other transports still use the SSH connection
+		if t.sshSession != nil {
+			if err := t.sshSession.Close(); err != nil && err != io.EOF {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := t.closeClient()
+	t.closeJumpHosts()
+	return err
+}
+
+// OpenChannel opens a new NETCONF transport on the SSH connection of t, using a new channel requesting
+// the netconf subsystem as allowed by RFC6242. This allows for instance a dedicated notification session
+// next to a configuration session, without a second SSH handshake.
+// https://datatracker.ietf.org/doc/html/rfc6242#section-3.1
+//
+// The SSH connection is closed once every transport sharing it has been closed.
+func (t *TransportSSH) OpenChannel() (*TransportSSH, error) {
+	if t.conn == nil || !t.conn.acquire() {
+		return nil, ErrSSHConnectionClosed
+	}
+
+	channel := &TransportSSH{
+		sshClient:   t.sshClient,
+		jumpClients: t.jumpClients,
+		conn:        t.conn,
+	}
+	if err := channel.setupSession(); err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+	return channel, nil
+}
+
+// ErrSSHConnectionClosed is returned by OpenChannel when the SSH connection was closed.
+var ErrSSHConnectionClosed = errors.New("ssh: connection closed")
+
+// sshConn is the state of an SSH connection shared by the transports using it.
+type sshConn struct {
+	client *ssh.Client
+
+	mu sync.Mutex
+	// count is the number of transports using the connection
+	count int
+	// keepaliveStop stops the keepalive of the connection, if started
+	keepaliveStop chan struct{}
+	// err is the reason the connection was closed, when it was not closed by the client
+	err error
+}
+
+// acquire adds a reference, unless the connection was already released by every transport.
+func (c *sshConn) acquire() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.count == 0 {
+		return false
+	}
+	c.count++
+	return true
+}
+
+// release removes a reference, and reports whether it was the last one. The keepalive is
+// stopped along with the last reference.
+func (c *sshConn) release() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count--
+	if c.count > 0 {
+		return false
+	}
+	if c.keepaliveStop != nil {
+		close(c.keepaliveStop)
+		c.keepaliveStop = nil
+	}
+	return true
+}
+
+func (t *TransportSSH) closeClient() error {
 	// Close the SSH Session if we have one
 	if t.sshSession != nil {
 		if err := t.sshSession.Close(); err != nil {
@@ -81,23 +182,145 @@ func (t *TransportSSH) Dial(target string, config *ssh.ClientConfig) error {
 
 	t.sshClient, err = ssh.Dial("tcp", target, config)
 	if err != nil {
-		return err
+		return sshHandshakeError(err)
 	}
 
 	err = t.setupSession()
 	return err
 }
 
+// DialVia connects and establishes SSH sessions like Dial, reaching the target through the provided dialer
+// and chain of jump hosts. The first jump host, or the target when there is none, is dialed using dialer,
+// which defaults to a net.Dialer; see SOCKS5Dialer and HTTPConnectDialer to go through a proxy. Every
+// following hop is then reached through the SSH connection to the previous jump host.
+//
+// All the connections to the jump hosts are closed along with the transport.
+func (t *TransportSSH) DialVia(dialer Dialer, target string, config *ssh.ClientConfig, jumpHosts ...SSHJumpHost) error {
+	if !strings.Contains(target, ":") {
+		target = fmt.Sprintf("%s:%d", target, sshDefaultPort)
+	}
+	dialer = forward(dialer)
+
+	for _, jumpHost := range jumpHosts {
+		address := jumpHost.Address
+		if !strings.Contains(address, ":") {
+			address = fmt.Sprintf("%s:%d", address, sshJumpHostDefaultPort)
+		}
+		client, err := dialSSHClient(dialer, address, jumpHost.Config)
+		if err != nil {
+			t.closeJumpHosts()
+			return fmt.Errorf("jump host %s: %w", address, err)
+		}
+		t.jumpClients = append(t.jumpClients, client)
+		dialer = client
+	}
+
+	var err error
+	t.sshClient, err = dialSSHClient(dialer, target, config)
+	if err != nil {
+		t.closeJumpHosts()
+		return err
+	}
+
+	return t.setupSession()
+}
+
+// SSHJumpHost describes an SSH server used as a bastion to reach the next hop, like the ProxyJump
+// option of OpenSSH.
+type SSHJumpHost struct {
+	// Address can be a host, which utilizes the default SSH port of 22, or specify a port with
+	// the following format <host>:<port>.
+	Address string
+	// Config is used to authenticate against the jump host.
+	Config *ssh.ClientConfig
+}
+
+func dialSSHClient(dialer Dialer, address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := dialer.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, sshHandshakeError(err)
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// closeJumpHosts closes the connections to the jump hosts, starting from the closest to the target.
+func (t *TransportSSH) closeJumpHosts() {
+	for i := len(t.jumpClients) - 1; i >= 0; i-- {
+		_ = t.jumpClients[i].Close()
+	}
+	t.jumpClients = nil
+}
+
 // DialSSH creates a new SSH Transport.
 // See TransportSSH.Dial for arguments.
 func DialSSH(target string, config *ssh.ClientConfig) (*TransportSSH, error) {
 	t := new(TransportSSH)
 	err := t.Dial(target, config)
 	if err != nil {
-		err := t.Close()
+		if t.sshClient != nil {
+			_ = t.Close()
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// dialSSHContext creates a new SSH Transport like DialSSH, bounding the connection establishment, including
+// the SSH handshake, by ctx.
+func dialSSHContext(ctx context.Context, target string, config *ssh.ClientConfig) (*TransportSSH, error) {
+	if !strings.Contains(target, ":") {
+		target = fmt.Sprintf("%s:%d", target, sshDefaultPort)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return nil, err
+	}
+	// Closing the connection aborts the handshake once ctx is done.
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+
+	t, err := func() (*TransportSSH, error) {
+		c, chans, reqs, err := ssh.NewClientConn(conn, target, config)
 		if err != nil {
+			return nil, sshHandshakeError(err)
+		}
+		t := &TransportSSH{sshClient: ssh.NewClient(c, chans, reqs)}
+		if err := t.setupSession(); err != nil {
+			_ = t.sshClient.Close()
 			return nil, err
 		}
+		return t, nil
+	}()
+	if !stop() {
+		if t != nil {
+			_ = t.Close()
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// DialSSHVia creates a new SSH Transport reaching the target through a dialer and a chain of jump hosts.
+// See TransportSSH.DialVia for arguments.
+func DialSSHVia(dialer Dialer, target string, config *ssh.ClientConfig, jumpHosts ...SSHJumpHost) (*TransportSSH, error) {
+	t := new(TransportSSH)
+	err := t.DialVia(dialer, target, config, jumpHosts...)
+	if err != nil {
+		if t.sshClient != nil {
+			_ = t.Close()
+		}
 		return nil, err
 	}
 	return t, nil
@@ -105,36 +328,26 @@ func DialSSH(target string, config *ssh.ClientConfig) (*TransportSSH, error) {
 
 // DialSSHTimeout creates a new SSH Transport with timeout.
 // See TransportSSH.Dial for arguments.
-// The timeout value is used for both connection establishment and Read/Write operations.
+// The timeout value is used for connection establishment, including the SSH handshake, and for every
+// write operation. Reads are not bounded, so an idle session such as a notification session is not
+// affected: instead, keepalive requests are sent every timeout/2, and the connection is closed when
+// two consecutive requests are left unanswered. See WithKeepalive to configure the keepalive.
 func DialSSHTimeout(target string, config *ssh.ClientConfig, timeout time.Duration) (*TransportSSH, error) {
 	bareConn, err := net.DialTimeout("tcp", target, timeout)
 	if err != nil {
 		return nil, err
 	}
 
-	conn := &deadlineConn{Conn: bareConn, timeout: timeout}
+	_ = bareConn.SetDeadline(time.Now().Add(timeout))
+	conn := &deadlineConn{Conn: bareConn, writeTimeout: timeout}
 	t, err := connToTransport(conn, config)
 	if err != nil {
-		if t != nil {
-			err := t.Close()
-			if err != nil {
-				return nil, err
-			}
-		}
+		_ = bareConn.Close()
 		return nil, err
 	}
+	_ = bareConn.SetReadDeadline(time.Time{})
 
-	go func() {
-		ticker := time.NewTicker(timeout / 2)
-		defer ticker.Stop()
-		for range ticker.C {
-			_, _, err := t.sshClient.Conn.SendRequest("KEEP_ALIVE", true, nil)
-			if err != nil {
-				return
-			}
-		}
-	}()
-
+	t.StartKeepalive(KeepaliveConfig{Interval: timeout / 2, MaxMissed: 2})
 	return t, nil
 }
 
@@ -144,10 +357,7 @@ func NoDialSSH(sshClient *ssh.Client) (*TransportSSH, error) {
 	t.sshClient = sshClient
 	err := t.setupSession()
 	if err != nil {
-		err := t.Close()
-		if err != nil {
-			return nil, err
-		}
+		_ = t.Close()
 		return nil, err
 	}
 	return t, nil
@@ -155,29 +365,14 @@ func NoDialSSH(sshClient *ssh.Client) (*TransportSSH, error) {
 
 // SSHConfigPubKeyFile is a convenience function that takes a username, private key
 // and passphrase and returns a new ssh.ClientConfig setup to pass credentials
-// to DialSSH
+// to DialSSH. See NewSSHClientConfig to combine several sources of credentials.
 func SSHConfigPubKeyFile(user string, file string, passphrase string) (*ssh.ClientConfig, error) {
 	buf, err := os.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
-	block, rest := pem.Decode(buf)
-	if len(rest) > 0 {
-		return nil, fmt.Errorf("pem: unable to decode file %s", file)
-	}
 
-	if x509.IsEncryptedPEMBlock(block) {
-		b, err := x509.DecryptPEMBlock(block, []byte(passphrase))
-		if err != nil {
-			return nil, err
-		}
-		buf = pem.EncodeToMemory(&pem.Block{
-			Type:  block.Type,
-			Bytes: b,
-		})
-	}
-
-	key, err := ssh.ParsePrivateKey(buf)
+	key, err := ParsePrivateKey(buf, passphrase)
 	if err != nil {
 		return nil, err
 	}
@@ -193,7 +388,7 @@ func SSHConfigPubKeyFile(user string, file string, passphrase string) (*ssh.Clie
 func connToTransport(conn net.Conn, config *ssh.ClientConfig) (*TransportSSH, error) {
 	c, channel, reqs, err := ssh.NewClientConn(conn, conn.RemoteAddr().String(), config)
 	if err != nil {
-		return nil, err
+		return nil, sshHandshakeError(err)
 	}
 
 	t := &TransportSSH{}
@@ -207,24 +402,25 @@ func connToTransport(conn net.Conn, config *ssh.ClientConfig) (*TransportSSH, er
 	return t, nil
 }
 
+// deadlineConn bounds every write operation. Reads are left unbounded, as a session may legitimately
+// stay idle; the liveness of the peer is checked using keepalive requests instead.
 type deadlineConn struct {
 	net.Conn
-	timeout time.Duration
-}
-
-func (c *deadlineConn) Read(b []byte) (n int, err error) {
-	_ = c.SetReadDeadline(time.Now().Add(c.timeout))
-	return c.Conn.Read(b)
+	writeTimeout time.Duration
 }
 
 func (c *deadlineConn) Write(b []byte) (n int, err error) {
-	_ = c.SetWriteDeadline(time.Now().Add(c.timeout))
+	_ = c.SetWriteDeadline(time.Now().Add(c.writeTimeout))
 	return c.Conn.Write(b)
 }
 
 func (t *TransportSSH) setupSession() error {
 	var err error
 
+	if t.conn == nil {
+		t.conn = &sshConn{client: t.sshClient, count: 1}
+	}
+
 	t.sshSession, err = t.sshClient.NewSession()
 	if err != nil {
 		return err
@@ -240,6 +436,6 @@ func (t *TransportSSH) setupSession() error {
 		return err
 	}
 
-	t.ReadWriteCloser = NewReadWriteCloser(reader, writer)
+	t.ReadWriteCloser = NewReadWriteCloser(&sshConnReader{reader, t.conn}, &sshConnWriter{writer, t.conn})
 	return t.sshSession.RequestSubsystem(sshNetconfSubsystem)
 }
@@ -0,0 +1,239 @@
+package netconf
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// tlsDefaultPort is the default port used when communicating with NETCONF over TLS
+	// https://datatracker.ietf.org/doc/html/rfc7589#section-3
+	tlsDefaultPort = 6513
+)
+
+// TransportTLS maintains the information necessary to communicate with the
+// remote device over TLS
+type TransportTLS struct {
+	transportBasicIO
+	tlsConn *tls.Conn
+}
+
+// Close closes the TLS connection if it exists.
+func (t *TransportTLS) Close() error {
+	// If TransportTLS is nil ignore closing tls connection
+	if t == nil {
+		return nil
+	}
+
+	if t.tlsConn != nil {
+		return t.tlsConn.Close()
+	}
+	return fmt.Errorf("no connection to close")
+}
+
+// ConnectionState returns basic TLS details about the connection, such as the
+// certificates presented by the NETCONF server.
+func (t *TransportTLS) ConnectionState() tls.ConnectionState {
+	return t.tlsConn.ConnectionState()
+}
+
+// Dial connects and establishes a TLS connection.
+//
+// target can be an IP address (e.g.) 172.16.1.1 which utilizes the default
+// NETCONF over TLS port of 6513. Target can also specify a port with the
+// following format <host>:<port (e.g. 172.16.1.1:6514)
+//
+// config takes a tls.Config. As mandated by RFC7589, the client must present
+// a certificate; see TLSConfigFromFiles for a helper building such configuration.
+func (t *TransportTLS) Dial(target string, config *tls.Config) error {
+	return t.dial(&net.Dialer{}, target, config)
+}
+
+func (t *TransportTLS) dial(dialer *net.Dialer, target string, config *tls.Config) error {
+	if !strings.Contains(target, ":") {
+		target = fmt.Sprintf("%s:%d", target, tlsDefaultPort)
+	}
+
+	conn, err := tls.DialWithDialer(dialer, "tcp", target, config)
+	if err != nil {
+		return err
+	}
+
+	t.setupConn(conn)
+	return nil
+}
+
+func (t *TransportTLS) setupConn(conn *tls.Conn) {
+	t.tlsConn = conn
+	t.ReadWriteCloser = conn
+}
+
+// DialTLS creates a new TLS Transport.
+// See TransportTLS.Dial for arguments.
+func DialTLS(target string, config *tls.Config) (*TransportTLS, error) {
+	t := new(TransportTLS)
+	err := t.Dial(target, config)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// DialTLSTimeout creates a new TLS Transport with timeout.
+// See TransportTLS.Dial for arguments.
+// The timeout value is used for connection establishment, including the TLS handshake.
+func DialTLSTimeout(target string, config *tls.Config, timeout time.Duration) (*TransportTLS, error) {
+	t := new(TransportTLS)
+	err := t.dial(&net.Dialer{Timeout: timeout}, target, config)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// TLSConfigFromFiles is a convenience function that builds a tls.Config suitable for DialTLS.
+// certFile and keyFile hold the PEM encoded client certificate and its private key, and caFile holds
+// the PEM encoded certificate authorities used to verify the NETCONF server. When caFile is empty, the
+// system certificate pool is used.
+func TLSConfigFromFiles(certFile string, keyFile string, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no certificate found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+// CertToName describes what is expected from the certificate presented by the NETCONF server.
+// It mirrors the cert-to-name mapping of RFC7589 and RFC7407: a certificate matches when its
+// fingerprint matches Fingerprint, when set, and when one of its names matches Name, when set.
+type CertToName struct {
+	// Fingerprint uses the tls-fingerprint format from RFC7407: a one octet hash algorithm identifier
+	// followed by the certificate hash, all hex encoded and colon separated (e.g. 04:8A:2F:...).
+	Fingerprint string
+	// Name is matched against the DNS names, IP addresses and email addresses of the certificate
+	// subject alternative names, and against the subject common name.
+	Name string
+}
+
+// ErrCertToName indicates the certificate presented by the server did not match any CertToName expectation.
+var ErrCertToName = errors.New("tls: server certificate does not match any cert-to-name expectation")
+
+// tlsHashAlgorithms maps the TLS HashAlgorithm identifiers used by tls-fingerprint to their implementation.
+// https://datatracker.ietf.org/doc/html/rfc5246#section-7.4.1.4.1
+var tlsHashAlgorithms = map[byte]func([]byte) []byte{
+	2: func(b []byte) []byte { h := sha1.Sum(b); return h[:] },
+	3: func(b []byte) []byte { h := sha256.Sum224(b); return h[:] },
+	4: func(b []byte) []byte { h := sha256.Sum256(b); return h[:] },
+	5: func(b []byte) []byte { h := sha512.Sum384(b); return h[:] },
+	6: func(b []byte) []byte { h := sha512.Sum512(b); return h[:] },
+}
+
+// CertificateFingerprint returns the tls-fingerprint of the certificate using SHA-256.
+func CertificateFingerprint(cert *x509.Certificate) string {
+	return formatFingerprint(4, tlsHashAlgorithms[4](cert.Raw))
+}
+
+func formatFingerprint(algorithm byte, sum []byte) string {
+	parts := make([]string, 0, len(sum)+1)
+	parts = append(parts, fmt.Sprintf("%02X", algorithm))
+	for _, b := range sum {
+		parts = append(parts, fmt.Sprintf("%02X", b))
+	}
+	return strings.Join(parts, ":")
+}
+
+// Matches reports whether the provided certificate satisfies the expectation.
+func (c CertToName) Matches(cert *x509.Certificate) (bool, error) {
+	if c.Fingerprint != "" {
+		raw, err := hex.DecodeString(strings.ReplaceAll(c.Fingerprint, ":", ""))
+		if err != nil || len(raw) < 2 {
+			return false, fmt.Errorf("tls: invalid fingerprint %q", c.Fingerprint)
+		}
+		hash, ok := tlsHashAlgorithms[raw[0]]
+		if !ok {
+			return false, fmt.Errorf("tls: unsupported fingerprint hash algorithm %d", raw[0])
+		}
+		if !bytes.Equal(hash(cert.Raw), raw[1:]) {
+			return false, nil
+		}
+	}
+
+	if c.Name != "" {
+		return certificateHasName(cert, c.Name), nil
+	}
+
+	return true, nil
+}
+
+func certificateHasName(cert *x509.Certificate, name string) bool {
+	for _, dns := range cert.DNSNames {
+		if strings.EqualFold(dns, name) {
+			return true
+		}
+	}
+	for _, ip := range cert.IPAddresses {
+		if ip.String() == name {
+			return true
+		}
+	}
+	for _, email := range cert.EmailAddresses {
+		if email == name {
+			return true
+		}
+	}
+	return cert.Subject.CommonName == name
+}
+
+// VerifyCertToName returns a function suitable for tls.Config.VerifyPeerCertificate, rejecting the connection
+// unless the leaf certificate presented by the server matches at least one of the provided expectations.
+// It is applied on top of the regular chain verification done against tls.Config.RootCAs.
+func VerifyCertToName(expectations ...CertToName) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return ErrCertToName
+		}
+		leaf, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return err
+		}
+		for _, expectation := range expectations {
+			ok, err := expectation.Matches(leaf)
+			if err != nil {
+				return err
+			}
+			if ok {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrCertToName, CertificateFingerprint(leaf))
+	}
+}
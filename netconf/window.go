@@ -0,0 +1,112 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrWindowFull indicates an RPC was rejected because the maximum number of RPCs in flight was reached,
+// the session being configured using WithWindowFailFast.
+var ErrWindowFull = errors.New("netconf: too many RPCs in flight")
+
+// WithMaxInFlight bounds the number of RPCs sent on the session and still waiting for their reply. Once max
+// RPCs are in flight, the senders wait for a reply to release a slot, within the deadline of their context.
+// Zero, the default, does not bound the RPCs in flight.
+func WithMaxInFlight(max int) SessionOption {
+	return func(s *Session) {
+		s.maxInFlight = max
+	}
+}
+
+// WithWindowFailFast makes the RPCs fail with ErrWindowFull when the maximum number of RPCs in flight is reached,
+// instead of waiting for a slot.
+func WithWindowFailFast() SessionOption {
+	return func(s *Session) {
+		s.windowFailFast = true
+	}
+}
+
+// Stats are the statistics of the RPCs executed by a session.
+type Stats struct {
+	// MaxInFlight is the maximum number of RPCs in flight, or zero when it is not bounded.
+	MaxInFlight int
+	// InFlight is the number of RPCs sent, or being sent, and waiting for their reply.
+	InFlight int
+	// Queued is the number of RPCs waiting for a slot before being sent.
+	Queued int
+	// Sent is the number of messages sent since the session was created, excluding the hello message.
+	Sent uint64
+	// Rejected is the number of RPCs which failed with ErrWindowFull.
+	Rejected uint64
+}
+
+// Stats returns the statistics of the RPCs executed by the session.
+func (session *Session) Stats() Stats {
+	w := session.window
+	return Stats{
+		MaxInFlight: cap(w.slots),
+		InFlight:    int(w.inFlight.Load()),
+		Queued:      int(w.queued.Load()),
+		Sent:        w.sent.Load(),
+		Rejected:    w.rejected.Load(),
+	}
+}
+
+// window bounds the RPCs in flight on a session.
+type window struct {
+	// slots holds a token for every RPC in flight. It is nil when the RPCs in flight are not bounded.
+	slots    chan struct{}
+	failFast bool
+
+	inFlight atomic.Int64
+	queued   atomic.Int64
+	sent     atomic.Uint64
+	rejected atomic.Uint64
+}
+
+func newWindow(max int, failFast bool) *window {
+	w := &window{failFast: failFast}
+	if max > 0 {
+		w.slots = make(chan struct{}, max)
+	}
+	return w
+}
+
+// acquire waits for a slot, until ctx or the session is done. The returned function releases the slot, and can
+// be invoked several times.
+func (w *window) acquire(ctx context.Context, session *Session) (func(), error) {
+	if w.slots != nil {
+		select {
+		case w.slots <- struct{}{}:
+		default:
+			if w.failFast {
+				w.rejected.Add(1)
+				return nil, ErrWindowFull
+			}
+			w.queued.Add(1)
+			select {
+			case w.slots <- struct{}{}:
+				w.queued.Add(-1)
+			case <-ctx.Done():
+				w.queued.Add(-1)
+				return nil, ctx.Err()
+			case <-session.Done():
+				w.queued.Add(-1)
+				return nil, session.Err()
+			}
+		}
+	}
+
+	w.inFlight.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			w.inFlight.Add(-1)
+			if w.slots != nil {
+				<-w.slots
+			}
+		})
+	}, nil
+}
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"golang.org/x/crypto/ssh"
+)
+
+// listenLegacySSH starts a NETCONF server only supporting the algorithms of old network gear.
+func listenLegacySSH(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("failed to create host key signer: %v", err)
+	}
+	config := &ssh.ServerConfig{
+		Config: ssh.Config{
+			KeyExchanges: []string{"diffie-hellman-group1-sha1"},
+			Ciphers:      []string{"aes128-cbc"},
+			MACs:         []string{"hmac-sha1"},
+		},
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == sshUser && string(password) == sshPassword {
+				return nil, nil
+			}
+			return nil, errAuthFailed
+		},
+	}
+	config.AddHostKey(signer)
+	return listenSSH(t, config, rpcHandler(1, okReply)).Addr().String()
+}
+
+func TestSSHAlgorithmsLegacy(t *testing.T) {
+	address := listenLegacySSH(t)
+
+	t.Run("DialSSH", func(t *testing.T) {
+		transport, err := netconf.DialSSH(address, netconf.SSHAlgorithmsLegacy.Apply(sshClientConfig()))
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		session, err := netconf.NewSession(transport)
+		if err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+		defer session.Close()
+		commit(t, session)
+	})
+
+	t.Run("DialSSHTimeout", func(t *testing.T) {
+		transport, err := netconf.DialSSHTimeout(address, netconf.SSHAlgorithmsLegacy.Apply(sshClientConfig()), 5*time.Second)
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		_ = transport.Close()
+	})
+}
+
+func TestSSHAlgorithmsModernRSAHostKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("failed to create host key signer: %v", err)
+	}
+	// The server only supports SHA-2 signatures of its RSA host key.
+	signer, err = ssh.NewSignerWithAlgorithms(signer.(ssh.AlgorithmSigner), []string{ssh.KeyAlgoRSASHA256})
+	if err != nil {
+		t.Fatalf("failed to create host key signer: %v", err)
+	}
+	config := &ssh.ServerConfig{
+		Config: ssh.Config{KeyExchanges: []string{"diffie-hellman-group14-sha256"}},
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == sshUser && string(password) == sshPassword {
+				return nil, nil
+			}
+			return nil, errAuthFailed
+		},
+	}
+	config.AddHostKey(signer)
+	address := listenSSH(t, config, rpcHandler(1, okReply)).Addr().String()
+
+	transport, err := netconf.DialSSHTimeout(address, netconf.SSHAlgorithmsModern.Apply(sshClientConfig()), 5*time.Second)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	session, err := netconf.NewSession(transport)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	defer session.Close()
+	commit(t, session)
+}
+
+func TestSSHAlgorithmMismatch(t *testing.T) {
+	address := listenLegacySSH(t)
+
+	dialers := map[string]func(*ssh.ClientConfig) (*netconf.TransportSSH, error){
+		"DialSSH": func(config *ssh.ClientConfig) (*netconf.TransportSSH, error) {
+			return netconf.DialSSH(address, config)
+		},
+		"DialSSHTimeout": func(config *ssh.ClientConfig) (*netconf.TransportSSH, error) {
+			return netconf.DialSSHTimeout(address, config, 5*time.Second)
+		},
+	}
+	for _, profile := range []netconf.SSHAlgorithmProfile{netconf.SSHAlgorithmsModern, netconf.SSHAlgorithmsCompatible} {
+		for name, dial := range dialers {
+			t.Run(profile.Name+"/"+name, func(t *testing.T) {
+				transport, err := dial(profile.Apply(sshClientConfig()))
+				if transport != nil {
+					t.Errorf("got a transport along with %v", err)
+				}
+				if !errors.Is(err, netconf.ErrAlgorithmMismatch) {
+					t.Fatalf("got %v, wanted %v", err, netconf.ErrAlgorithmMismatch)
+				}
+				var mismatch *netconf.AlgorithmMismatchError
+				if !errors.As(err, &mismatch) {
+					t.Fatalf("got %#v, wanted a *netconf.AlgorithmMismatchError", err)
+				}
+				if mismatch.What != "key exchange" {
+					t.Errorf("got %q, wanted key exchange", mismatch.What)
+				}
+				if len(mismatch.Server) != 1 || mismatch.Server[0] != "diffie-hellman-group1-sha1" {
+					t.Errorf("got server algorithms %v", mismatch.Server)
+				}
+				if len(mismatch.Client) != len(profile.KeyExchanges) {
+					t.Errorf("got client algorithms %v, wanted %v", mismatch.Client, profile.KeyExchanges)
+				}
+			})
+		}
+	}
+}
+
+func TestSSHAlgorithmProfileByName(t *testing.T) {
+	for _, name := range []string{"modern", "compatible", "legacy"} {
+		if p, err := netconf.SSHAlgorithmProfileByName(name); err != nil || p.Name != name {
+			t.Errorf("got %v, %v for %s", p.Name, err, name)
+		}
+	}
+	if _, err := netconf.SSHAlgorithmProfileByName("unknown"); err == nil {
+		t.Errorf("found unknown profile")
+	}
+}
@@ -0,0 +1,273 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const keyPassphrase = "netconf"
+
+func readResource(t *testing.T, name string) []byte {
+	b, err := os.ReadFile(filepath.Join("resources", name))
+	if err != nil {
+		t.Fatalf("failed to read resources: %v", err)
+	}
+	return b
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	for _, name := range []string{"ssh_ed25519_encrypted", "ssh_rsa_pem_encrypted"} {
+		t.Run(name, func(t *testing.T) {
+			pemBytes := readResource(t, name)
+
+			if _, err := netconf.ParsePrivateKey(pemBytes, keyPassphrase); err != nil {
+				t.Errorf("failed to parse key: %v", err)
+			}
+			// Data following the key is ignored.
+			if _, err := netconf.ParsePrivateKey(append(pemBytes, "trailing data\n"...), keyPassphrase); err != nil {
+				t.Errorf("failed to parse key followed by data: %v", err)
+			}
+			if _, err := netconf.ParsePrivateKey(pemBytes, ""); !errors.Is(err, netconf.ErrPassphraseRequired) {
+				t.Errorf("got %v, wanted %v", err, netconf.ErrPassphraseRequired)
+			}
+			if _, err := netconf.ParsePrivateKey(pemBytes, "wrong"); err == nil {
+				t.Errorf("parsed key using a wrong passphrase")
+			}
+		})
+	}
+
+	// A passphrase provided for a key which is not encrypted is ignored.
+	if _, err := netconf.ParsePrivateKey(readResource(t, "ssh_user"), keyPassphrase); err != nil {
+		t.Errorf("failed to parse unencrypted key: %v", err)
+	}
+}
+
+// authServer starts a NETCONF server accepting the provided public keys, certificates signed by the provided
+// user authority, and keyboard-interactive answering sshPassword.
+func authServer(t *testing.T, authorizedKeys []ssh.PublicKey, userAuthority ssh.PublicKey) string {
+	config, _ := newSSHServerConfig(t)
+	config.PasswordCallback = nil
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return userAuthority != nil && bytes.Equal(auth.Marshal(), userAuthority.Marshal())
+		},
+		UserKeyFallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			for _, authorized := range authorizedKeys {
+				if bytes.Equal(authorized.Marshal(), key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, errAuthFailed
+		},
+	}
+	config.PublicKeyCallback = checker.Authenticate
+	config.KeyboardInteractiveCallback = func(conn ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+		answers, err := challenge("netconf", "login", []string{"Password: "}, []bool{false})
+		if err != nil {
+			return nil, err
+		}
+		if len(answers) != 1 || answers[0] != sshPassword {
+			return nil, errAuthFailed
+		}
+		return nil, nil
+	}
+	return listenSSH(t, config, rpcHandler(1, okReply)).Addr().String()
+}
+
+func connect(t *testing.T, address string, options ...netconf.SSHAuthOption) error {
+	config, err := netconf.NewSSHClientConfig(sshUser, ssh.InsecureIgnoreHostKey(), options...)
+	if err != nil {
+		t.Fatalf("failed to build config: %v", err)
+	}
+	session, err := netconf.NewSessionFromSSHConfig(address, config)
+	if err != nil {
+		return err
+	}
+	commit(t, session)
+	return session.Close()
+}
+
+func TestSSHAuthPrivateKeyFile(t *testing.T) {
+	signer, err := netconf.ParsePrivateKey(readResource(t, "ssh_ed25519_encrypted"), keyPassphrase)
+	if err != nil {
+		t.Fatalf("failed to parse key: %v", err)
+	}
+	address := authServer(t, []ssh.PublicKey{signer.PublicKey()}, nil)
+
+	if err := connect(t, address, netconf.WithPrivateKeyFile("resources/ssh_ed25519_encrypted", keyPassphrase)); err != nil {
+		t.Errorf("failed to authenticate: %v", err)
+	}
+
+	config, err := netconf.SSHConfigPubKeyFile(sshUser, "resources/ssh_ed25519_encrypted", keyPassphrase)
+	if err != nil {
+		t.Fatalf("failed to build config: %v", err)
+	}
+	config.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	session, err := netconf.NewSessionFromSSHConfig(address, config)
+	if err != nil {
+		t.Fatalf("failed to authenticate using SSHConfigPubKeyFile: %v", err)
+	}
+	_ = session.Close()
+}
+
+func TestSSHAuthCertificate(t *testing.T) {
+	ca, _, _, _, err := ssh.ParseAuthorizedKey(readResource(t, "ssh_user_ca.pub"))
+	if err != nil {
+		t.Fatalf("failed to parse authority: %v", err)
+	}
+	address := authServer(t, nil, ca)
+
+	if err := connect(t, address, netconf.WithCertificateFile("resources/ssh_user", "resources/ssh_user-cert.pub", "")); err != nil {
+		t.Errorf("failed to authenticate: %v", err)
+	}
+	if err := connect(t, address, netconf.WithPrivateKeyFile("resources/ssh_user", "")); err == nil {
+		t.Errorf("authenticated without the certificate")
+	}
+}
+
+// startAgent serves an in-memory ssh-agent holding a new key on a UNIX socket.
+func startAgent(t *testing.T) (string, ssh.PublicKey) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return serveAgent(t, key)
+}
+
+// serveAgent starts an agent holding key, and returns its socket along with the public key.
+func serveAgent(t *testing.T, key interface{}) (string, ssh.PublicKey) {
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatalf("failed to add key to agent: %v", err)
+	}
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	signers, err := keyring.Signers()
+	if err != nil {
+		t.Fatalf("failed to list agent keys: %v", err)
+	}
+	return socket, signers[0].PublicKey()
+}
+
+func TestSSHAuthAgent(t *testing.T) {
+	socket, key := startAgent(t)
+	address := authServer(t, []ssh.PublicKey{key}, nil)
+
+	t.Setenv("SSH_AUTH_SOCK", socket)
+	if err := connect(t, address, netconf.WithSSHAgent()); err != nil {
+		t.Errorf("failed to authenticate: %v", err)
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if _, err := netconf.NewSSHClientConfig(sshUser, ssh.InsecureIgnoreHostKey(), netconf.WithSSHAgent()); err == nil {
+		t.Errorf("built config without agent")
+	}
+}
+
+func TestSSHAuthAgentRSASHA2(t *testing.T) {
+	key, err := ssh.ParseRawPrivateKeyWithPassphrase(readResource(t, "ssh_rsa_pem_encrypted"), []byte(keyPassphrase))
+	if err != nil {
+		t.Fatalf("failed to parse key: %v", err)
+	}
+	socket, pub := serveAgent(t, key)
+
+	config, _ := newSSHServerConfig(t)
+	config.PasswordCallback = nil
+	config.PublicKeyAuthAlgorithms = []string{ssh.KeyAlgoRSASHA256}
+	config.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+		if !bytes.Equal(key.Marshal(), pub.Marshal()) {
+			return nil, errAuthFailed
+		}
+		return nil, nil
+	}
+	address := listenSSH(t, config, rpcHandler(1, okReply)).Addr().String()
+
+	t.Setenv("SSH_AUTH_SOCK", socket)
+	if err := connect(t, address, netconf.WithSSHAgent()); err != nil {
+		t.Errorf("failed to authenticate using rsa-sha2-256: %v", err)
+	}
+}
+
+func TestSSHAuthKeyboardInteractive(t *testing.T) {
+	address := authServer(t, nil, nil)
+
+	var questions []string
+	prompt := func(name, instruction, question string, echo bool) (string, error) {
+		questions = append(questions, question)
+		return sshPassword, nil
+	}
+	if err := connect(t, address, netconf.WithKeyboardInteractive(prompt)); err != nil {
+		t.Errorf("failed to authenticate: %v", err)
+	}
+	if len(questions) != 1 || questions[0] != "Password: " {
+		t.Errorf("got questions %v", questions)
+	}
+}
+
+func TestSSHAuthOrder(t *testing.T) {
+	socket, key := startAgent(t)
+	address := authServer(t, []ssh.PublicKey{key}, nil)
+
+	// The key file is not authorized: the agent keys must still be tried, although the ssh package
+	// tries every authentication method only once.
+	err := connect(t, address,
+		netconf.WithPrivateKeyFile("resources/ssh_user", ""),
+		netconf.WithSSHAgentSocket(socket),
+		netconf.WithKeyboardInteractive(func(string, string, string, bool) (string, error) {
+			return "", errors.New("keyboard-interactive must not be reached")
+		}),
+	)
+	if err != nil {
+		t.Errorf("failed to authenticate: %v", err)
+	}
+}
+
+func TestSSHAuthUnreachableAgent(t *testing.T) {
+	config, _ := newSSHServerConfig(t)
+	address := listenSSH(t, config, rpcHandler(1, okReply)).Addr().String()
+	socket := filepath.Join(t.TempDir(), "missing.sock")
+
+	// The agent cannot be reached: the password must still be tried.
+	if err := connect(t, address, netconf.WithSSHAgentSocket(socket), netconf.WithPassword(sshPassword)); err != nil {
+		t.Errorf("failed to authenticate: %v", err)
+	}
+
+	// Without any other method, the failure to reach the agent is reported.
+	clientConfig, err := netconf.NewSSHClientConfig(sshUser, ssh.InsecureIgnoreHostKey(), netconf.WithSSHAgentSocket(socket))
+	if err != nil {
+		t.Fatalf("failed to build config: %v", err)
+	}
+	if _, err := netconf.DialSSHTimeout(authServer(t, nil, nil), clientConfig, 5*time.Second); err == nil || !strings.Contains(err.Error(), "agent") {
+		t.Errorf("got %v, wanted the agent error", err)
+	}
+}
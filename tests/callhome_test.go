@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"golang.org/x/crypto/ssh"
+)
+
+// callHomeSSH simulates a device calling home: it connects to address and acts as SSH server.
+func callHomeSSH(t *testing.T, address string, config *ssh.ServerConfig, handler netconfHandler) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("failed to call home: %v", err)
+	}
+	go serveSSH(conn, config, handler)
+}
+
+func TestCallHomeSSH(t *testing.T) {
+	serverConfig, hostKey := newSSHServerConfig(t)
+
+	listener, err := netconf.ListenCallHomeSSH("127.0.0.1:0", func(remote net.Addr) (*ssh.ClientConfig, error) {
+		config := sshClientConfig()
+		config.HostKeyCallback = ssh.FixedHostKey(hostKey.PublicKey())
+		return config, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- listener.Serve(nil)
+	}()
+
+	callHomeSSH(t, listener.Addr().String(), serverConfig, helloHandler(12))
+
+	select {
+	case s := <-listener.Sessions():
+		if s.SessionID != 12 {
+			t.Errorf("got session-id %d, wanted 12", s.SessionID)
+		}
+		if !bytes.Equal(s.Device.HostKey.Marshal(), hostKey.PublicKey().Marshal()) {
+			t.Errorf("device host key was not recorded")
+		}
+		if s.Device.RemoteAddr == nil {
+			t.Errorf("device remote address was not recorded")
+		}
+		_ = s.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the call home session")
+	}
+	if err := listener.Serve(nil); !errors.Is(err, netconf.ErrCallHomeListenerServing) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrCallHomeListenerServing)
+	}
+
+	if err := listener.Close(); err != nil {
+		t.Errorf("failed to close listener: %v", err)
+	}
+	if err := <-served; !errors.Is(err, netconf.ErrCallHomeListenerClosed) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrCallHomeListenerClosed)
+	}
+	if _, ok := <-listener.Sessions(); ok {
+		t.Errorf("expected sessions channel to be closed")
+	}
+}
+
+func TestCallHomeSSHHandler(t *testing.T) {
+	serverConfig, _ := newSSHServerConfig(t)
+	_, unknownHostKey := newSSHServerConfig(t)
+
+	listener, err := netconf.ListenCallHomeSSH("127.0.0.1:0", func(remote net.Addr) (*ssh.ClientConfig, error) {
+		config := sshClientConfig()
+		config.HostKeyCallback = ssh.FixedHostKey(unknownHostKey.PublicKey())
+		return config, nil
+	}, netconf.WithCallHomeHandshakeTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	sessions := make(chan *netconf.CallHomeSession, 1)
+	go func() {
+		_ = listener.Serve(func(s *netconf.CallHomeSession) {
+			sessions <- s
+		})
+	}()
+
+	// The device presents a host key that does not match the configuration, it must be rejected.
+	callHomeSSH(t, listener.Addr().String(), serverConfig, helloHandler(1))
+
+	select {
+	case <-sessions:
+		t.Errorf("expected device with unknown host key to be rejected")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := listener.Shutdown(ctx); err != nil {
+		t.Errorf("failed to shutdown listener: %v", err)
+	}
+}
+
+func TestCallHomeSSHNoConfig(t *testing.T) {
+	serverConfig, _ := newSSHServerConfig(t)
+
+	// A device without configuration is rejected.
+	listener, err := netconf.ListenCallHomeSSH("127.0.0.1:0", func(remote net.Addr) (*ssh.ClientConfig, error) {
+		return nil, nil
+	}, netconf.WithCallHomeHandshakeTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	sessions := make(chan *netconf.CallHomeSession, 1)
+	go func() {
+		_ = listener.Serve(func(s *netconf.CallHomeSession) {
+			sessions <- s
+		})
+	}()
+	callHomeSSH(t, listener.Addr().String(), serverConfig, helloHandler(1))
+
+	select {
+	case <-sessions:
+		t.Errorf("expected device without configuration to be rejected")
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+// callHomeTLS simulates a device calling home: it connects to address and acts as TLS server.
+func callHomeTLS(t *testing.T, address string, config *tls.Config, sessionID int) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("failed to call home: %v", err)
+	}
+	go func() {
+		tlsConn := tls.Server(conn, config)
+		defer tlsConn.Close()
+		if _, err := tlsConn.Write([]byte(serverHello(sessionID))); err != nil {
+			return
+		}
+		_, _ = readEOM(bufio.NewReader(tlsConn))
+	}()
+}
+
+func TestCallHomeTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	deviceCert := pki.issue("ru-1", x509.ExtKeyUsageServerAuth, "ru-1.example.com")
+	clientCert := pki.issue("admin", x509.ExtKeyUsageClientAuth)
+	deviceConfig := &tls.Config{
+		Certificates: []tls.Certificate{deviceCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pki.pool,
+	}
+
+	listener, err := netconf.ListenCallHomeTLS("127.0.0.1:0", func(remote net.Addr) (*tls.Config, error) {
+		return &tls.Config{Certificates: []tls.Certificate{clientCert}, RootCAs: pki.pool}, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		_ = listener.Serve(nil)
+	}()
+
+	callHomeTLS(t, listener.Addr().String(), deviceConfig, 21)
+
+	select {
+	case s := <-listener.Sessions():
+		defer s.Close()
+		if s.SessionID != 21 {
+			t.Errorf("got session-id %d, wanted 21", s.SessionID)
+		}
+		if got, want := s.Device.Fingerprint, netconf.CertificateFingerprint(deviceCert.Leaf); got != want {
+			t.Errorf("got fingerprint %s, wanted %s", got, want)
+		}
+		if len(s.Device.Certificate.DNSNames) != 1 || s.Device.Certificate.DNSNames[0] != "ru-1.example.com" {
+			t.Errorf("got SAN %v, wanted [ru-1.example.com]", s.Device.Certificate.DNSNames)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the call home session")
+	}
+}
+
+func TestCallHomeTLSUntrustedDevice(t *testing.T) {
+	pki := newTestPKI(t)
+	untrusted := newTestPKI(t)
+	deviceConfig := &tls.Config{
+		Certificates: []tls.Certificate{untrusted.issue("ru-1", x509.ExtKeyUsageServerAuth)},
+	}
+
+	listener, err := netconf.ListenCallHomeTLS("127.0.0.1:0", func(remote net.Addr) (*tls.Config, error) {
+		return &tls.Config{
+			Certificates: []tls.Certificate{pki.issue("admin", x509.ExtKeyUsageClientAuth)},
+			RootCAs:      pki.pool,
+		}, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		_ = listener.Serve(nil)
+	}()
+
+	callHomeTLS(t, listener.Addr().String(), deviceConfig, 1)
+
+	select {
+	case <-listener.Sessions():
+		t.Errorf("expected untrusted device to be rejected")
+	case <-time.After(500 * time.Millisecond):
+	}
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	capabilities := message.ParseCapabilities([]string{
+		message.NetconfVersion11,
+		message.CapabilityCandidate,
+		"urn:ietf:params:netconf:capability:validate:1.0",
+		"urn:ietf:params:netconf:capability:url:1.0?scheme=file,https",
+		"urn:ietf:params:netconf:capability:with-defaults:1.0?basic-mode=explicit&also-supported=report-all,trim",
+		"urn:example:interfaces?module=example-interfaces&amp;revision=2024-01-01&amp;features=ethernet,vlan&amp;deviations=vendor-deviations",
+		"  ",
+	})
+
+	for _, capability := range []string{":candidate", message.CapabilityCandidate, ":validate", message.NetconfVersion11, ":url", "urn:example:interfaces"} {
+		if !capabilities.Has(capability) {
+			t.Errorf("capability %s not found", capability)
+		}
+	}
+	for _, capability := range []string{":startup", message.CapabilityValidate, message.NetconfVersion10, ":interleave"} {
+		if capabilities.Has(capability) {
+			t.Errorf("capability %s found", capability)
+		}
+	}
+	if validate, _ := capabilities.Capability(":validate"); validate.Version != "1.0" {
+		t.Errorf("got :validate version %q, wanted 1.0", validate.Version)
+	}
+	if got := len(capabilities.All()); got != 6 {
+		t.Errorf("got %d capabilities, wanted 6", got)
+	}
+
+	if schemes := capabilities.URLSchemes(); !reflect.DeepEqual(schemes, []string{"file", "https"}) {
+		t.Errorf("got url schemes %v", schemes)
+	}
+	if basic, also := capabilities.WithDefaults(); basic != "explicit" || !reflect.DeepEqual(also, []string{"report-all", "trim"}) {
+		t.Errorf("got with-defaults modes %s %v", basic, also)
+	}
+
+	module, found := capabilities.Module("example-interfaces")
+	want := &message.Module{
+		Namespace:  "urn:example:interfaces",
+		Name:       "example-interfaces",
+		Revision:   "2024-01-01",
+		Features:   []string{"ethernet", "vlan"},
+		Deviations: []string{"vendor-deviations"},
+	}
+	if !found || !reflect.DeepEqual(module, want) {
+		t.Errorf("got module %+v, wanted %+v", module, want)
+	}
+	if !module.HasFeature("vlan") || module.HasFeature("lag") {
+		t.Errorf("got features %v", module.Features)
+	}
+	if _, found := capabilities.Module("missing"); found {
+		t.Errorf("missing module found")
+	}
+}
+
+func TestCapabilitiesCheck(t *testing.T) {
+	capabilities := message.ParseCapabilities([]string{message.NetconfVersion11, message.CapabilityCandidate})
+
+	for _, operation := range []message.RPCMethod{
+		message.NewCommit(),
+		message.NewLock(message.DatastoreCandidate),
+		message.NewGetConfig(message.DatastoreRunning, "", ""),
+		message.NewEditConfig(message.DatastoreCandidate, message.DefaultOperationTypeMerge, data),
+		message.NewRPC("<custom/>"),
+	} {
+		if err := capabilities.Check(operation); err != nil {
+			t.Errorf("got %v, wanted %T to be accepted", err, operation)
+		}
+	}
+
+	for operation, capability := range map[message.RPCMethod]string{
+		message.NewValidate(message.DatastoreCandidate):                                          ":validate",
+		message.NewEditConfig(message.DatastoreRunning, message.DefaultOperationTypeMerge, data): ":writable-running",
+		message.NewCopyConfig(message.DatastoreStartup, message.DatastoreRunning):                ":startup",
+		message.NewCreateSubscriptionDefault():                                                   ":notification",
+	} {
+		var capabilityErr *message.CapabilityError
+		err := capabilities.Check(operation)
+		if !errors.As(err, &capabilityErr) || !errors.Is(err, message.ErrCapabilityNotSupported) || capabilityErr.Capability != capability {
+			t.Errorf("got %v, wanted %T to require %s", err, operation, capability)
+		}
+	}
+}
+
+func TestSessionCapabilityCheck(t *testing.T) {
+	hello := serverHello(1, message.NetconfVersion11, message.CapabilityCandidate)
+	session := newTestSession(t, clientFirstHandler(hello, make(chan *message.Hello, 1)), netconf.WithCapabilityCheck())
+	defer session.Close()
+
+	if !session.ServerCapabilities().Has(":candidate") || session.ServerCapabilities().Has(":writable-running") {
+		t.Errorf("got server capabilities %v", session.Capabilities)
+	}
+	commit(t, session)
+
+	edit := message.NewEditConfig(message.DatastoreRunning, message.DefaultOperationTypeMerge, data)
+	if _, err := session.SyncRPC(edit, 5); !errors.Is(err, message.ErrCapabilityNotSupported) {
+		t.Errorf("got %v, wanted %v", err, message.ErrCapabilityNotSupported)
+	}
+	if err := session.AsyncRPC(message.NewValidate(message.DatastoreCandidate), func(netconf.Event) {}); !errors.Is(err, message.ErrCapabilityNotSupported) {
+		t.Errorf("got %v, wanted %v", err, message.ErrCapabilityNotSupported)
+	}
+	if err := session.CreateNotificationStream(5, "", "", "", func(netconf.Event) {}); !errors.Is(err, message.ErrCapabilityNotSupported) {
+		t.Errorf("got %v, wanted %v", err, message.ErrCapabilityNotSupported)
+	}
+
+	// The session is still usable after rejecting operations.
+	commit(t, session)
+}
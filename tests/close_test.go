@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+	"golang.org/x/crypto/ssh"
+)
+
+// closeSessionHandler answers close-session with reply, hanging up afterwards when it is <ok/>.
+func closeSessionHandler(reply func(messageID, request string) string) netconfHandler {
+	return func(ch ssh.Channel) {
+		if _, err := ch.Write([]byte(serverHello(1))); err != nil {
+			return
+		}
+		r := bufio.NewReader(ch)
+		if _, err := readEOM(r); err != nil {
+			return
+		}
+		for {
+			request, err := readChunked(r)
+			if err != nil {
+				return
+			}
+			response := reply(messageID(request), request)
+			if err := writeChunked(ch, response); err != nil {
+				return
+			}
+			if strings.Contains(request, "close-session") && strings.Contains(response, "<ok/>") {
+				return
+			}
+		}
+	}
+}
+
+func errorReply(messageID, request string) string {
+	return fmt.Sprintf(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%s"><rpc-error><error-type>protocol</error-type><error-tag>operation-failed</error-tag><error-severity>error</error-severity></rpc-error></rpc-reply>`, messageID)
+}
+
+func TestGracefulClose(t *testing.T) {
+	session := newTestSession(t, closeSessionHandler(okReply))
+
+	outcome, err := session.GracefulClose(context.Background())
+	if outcome != netconf.CloseAcknowledged || err != nil {
+		t.Errorf("got %s, %v, wanted %s", outcome, err, netconf.CloseAcknowledged)
+	}
+	select {
+	case <-session.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("session not closed")
+	}
+	var closedErr *netconf.SessionClosedError
+	if !errors.As(session.Err(), &closedErr) || closedErr.Cause != nil {
+		t.Errorf("got %v, wanted the session closed without cause", session.Err())
+	}
+
+	if outcome, err := session.GracefulClose(context.Background()); outcome != netconf.CloseAlreadyClosed || err != nil {
+		t.Errorf("got %s, %v, wanted %s", outcome, err, netconf.CloseAlreadyClosed)
+	}
+}
+
+func TestGracefulCloseUnacknowledged(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		session := newTestSession(t, heldHandler(release))
+
+		outcome, err := session.GracefulClose(context.Background(), netconf.WithCloseTimeout(100*time.Millisecond))
+		if outcome != netconf.CloseUnacknowledged || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got %s, %v, wanted %s after %v", outcome, err, netconf.CloseUnacknowledged, context.DeadlineExceeded)
+		}
+		if !session.Closed() {
+			t.Errorf("session not closed")
+		}
+	})
+
+	t.Run("rpc-error", func(t *testing.T) {
+		session := newTestSession(t, closeSessionHandler(errorReply))
+
+		outcome, err := session.GracefulClose(context.Background())
+		if outcome != netconf.CloseUnacknowledged || err == nil {
+			t.Errorf("got %s, %v, wanted %s with an error", outcome, err, netconf.CloseUnacknowledged)
+		}
+		if !session.Closed() {
+			t.Errorf("session not closed")
+		}
+	})
+}
+
+func TestGracefulCloseKill(t *testing.T) {
+	for name, force := range map[string]bool{"fallback": false, "force": true} {
+		t.Run(name, func(t *testing.T) {
+			release := make(chan struct{})
+			defer close(release)
+			wedged := newTestSession(t, heldHandler(release))
+
+			killed := make(chan string, 1)
+			killer := newTestSession(t, rpcHandler(2, func(messageID, request string) string {
+				killed <- request
+				return okReply(messageID, request)
+			}))
+			defer killer.Close()
+
+			option := netconf.WithKillFallback(killer)
+			if force {
+				option = netconf.WithForceKill(killer)
+			}
+			start := time.Now()
+			outcome, err := wedged.GracefulClose(context.Background(), netconf.WithCloseTimeout(100*time.Millisecond), option)
+			if outcome != netconf.CloseKilled || err != nil {
+				t.Errorf("got %s, %v, wanted %s", outcome, err, netconf.CloseKilled)
+			}
+			if force && time.Since(start) > 100*time.Millisecond {
+				t.Errorf("waited for close-session")
+			}
+			select {
+			case request := <-killed:
+				if !strings.Contains(request, "<kill-session><session-id>1</session-id></kill-session>") {
+					t.Errorf("got %s, wanted kill-session of session 1", request)
+				}
+			default:
+				t.Errorf("kill-session not received")
+			}
+			if !wedged.Closed() {
+				t.Errorf("session not closed")
+			}
+		})
+	}
+}
+
+func TestKillSessionError(t *testing.T) {
+	session := newTestSession(t, rpcHandler(1, errorReply))
+	defer session.Close()
+
+	if err := session.KillSession(context.Background(), 42); err == nil {
+		t.Errorf("got no error, wanted the rpc-error to be reported")
+	}
+}
+
+// answerHandler only answers the requests holding one of the operations, hanging up after close-session.
+func answerHandler(sessionID int, operations ...string) netconfHandler {
+	return func(ch ssh.Channel) {
+		if _, err := ch.Write([]byte(serverHello(sessionID))); err != nil {
+			return
+		}
+		r := bufio.NewReader(ch)
+		if _, err := readEOM(r); err != nil {
+			return
+		}
+		for {
+			request, err := readChunked(r)
+			if err != nil {
+				return
+			}
+			for _, operation := range operations {
+				if !strings.Contains(request, "<"+operation) {
+					continue
+				}
+				if err := writeChunked(ch, okReply(messageID(request), request)); err != nil {
+					return
+				}
+				if operation == "close-session" {
+					return
+				}
+			}
+		}
+	}
+}
+
+func TestGracefulCloseWindowFull(t *testing.T) {
+	options := []netconf.SessionOption{netconf.WithMaxInFlight(1), netconf.WithWindowFailFast()}
+	session := newTestSession(t, answerHandler(1, "close-session"), options...)
+	killer := newTestSession(t, answerHandler(2, "kill-session"), options...)
+	defer killer.Close()
+
+	// The commits are never answered, and keep the windows full.
+	for _, s := range []*netconf.Session{session, killer} {
+		if err := s.AsyncRPC(message.NewCommit(), func(netconf.Event) {}); err != nil {
+			t.Fatalf("failed to send rpc: %v", err)
+		}
+	}
+	if _, err := killer.SyncRPC(message.NewCommit(), 5); !errors.Is(err, netconf.ErrWindowFull) {
+		t.Fatalf("got %v, wanted %v", err, netconf.ErrWindowFull)
+	}
+
+	if err := killer.KillSession(context.Background(), 3); err != nil {
+		t.Errorf("failed to kill session with a full window: %v", err)
+	}
+	if outcome, err := session.GracefulClose(context.Background()); outcome != netconf.CloseAcknowledged || err != nil {
+		t.Errorf("got %s, %v, wanted %s", outcome, err, netconf.CloseAcknowledged)
+	}
+}
+
+func TestCloseOutcomeString(t *testing.T) {
+	if s := netconf.CloseKilled.String(); s != "killed" {
+		t.Errorf("got %q, wanted killed", s)
+	}
+	for _, o := range []netconf.CloseOutcome{-1, 42} {
+		if s, want := o.String(), fmt.Sprintf("CloseOutcome(%d)", o); s != want {
+			t.Errorf("got %q, wanted %q", s, want)
+		}
+	}
+}
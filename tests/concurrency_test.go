@@ -0,0 +1,252 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+)
+
+// memoryServer is an in-memory NETCONF server, connected to the client using net.Pipe.
+type memoryServer struct {
+	// malformed counts the requests which are not a single well-formed rpc, e.g. when frames got interleaved
+	malformed atomic.Int32
+}
+
+// newMemorySession returns a session connected to an in-memory server, which answers every rpc with <ok/>.
+// A <trigger/> rpc makes the server send a notification before the reply.
+func newMemorySession(t *testing.T, options ...netconf.SessionOption) (*netconf.Session, *memoryServer) {
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	s := &memoryServer{}
+	go s.serve(server)
+
+	session, err := netconf.NewSession(netconf.NewTransportConn(client), options...)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	t.Cleanup(func() { _ = session.Close() })
+	return session, s
+}
+
+func (s *memoryServer) serve(conn net.Conn) {
+	// net.Pipe is not buffered: the hello messages are exchanged simultaneously.
+	go func() { _, _ = conn.Write([]byte(serverHello(1))) }()
+	r := bufio.NewReader(conn)
+	if _, err := readEOM(r); err != nil {
+		return
+	}
+	for {
+		request, err := readChunked(r)
+		if err != nil {
+			return
+		}
+		if !wellFormed(request) {
+			s.malformed.Add(1)
+		}
+		if strings.Contains(request, "<trigger/>") {
+			if err := writeChunked(conn, eventNotification); err != nil {
+				return
+			}
+		}
+		if err := writeChunked(conn, okReply(messageID(request), request)); err != nil {
+			return
+		}
+	}
+}
+
+// wellFormed reports whether request holds a single well-formed rpc element.
+func wellFormed(request string) bool {
+	var rpc struct {
+		XMLName xml.Name `xml:"rpc"`
+	}
+	d := xml.NewDecoder(strings.NewReader(request))
+	if err := d.Decode(&rpc); err != nil {
+		return false
+	}
+	_, err := d.Token()
+	return err == io.EOF
+}
+
+func TestConcurrentRPCs(t *testing.T) {
+	// Small chunks make every message span several frames.
+	session, server := newMemorySession(t, netconf.WithChunkSize(16))
+
+	notifications := make(chan netconf.Event, 1000)
+	if err := session.CreateNotificationStream(5, "", "", "", func(e netconf.Event) { notifications <- e }); err != nil {
+		t.Fatalf("failed to create notification stream: %v", err)
+	}
+
+	const goroutines, calls = 20, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*calls)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < calls; i++ {
+				errs <- concurrentCall(session, (g+i)%4)
+				_ = session.Closed()
+				_ = session.NotificationStreamCreated()
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	if n := server.malformed.Load(); n != 0 {
+		t.Errorf("got %d malformed requests", n)
+	}
+	if pending := len(notifications); pending != goroutines*calls/4 {
+		t.Errorf("got %d notifications, wanted %d", pending, goroutines*calls/4)
+	}
+}
+
+// concurrentCall executes an rpc, using a different API depending on kind.
+func concurrentCall(session *netconf.Session, kind int) error {
+	switch kind {
+	case 0:
+		reply, err := session.SyncRPC(message.NewRPC("<trigger/>"), 5)
+		if err != nil {
+			return err
+		}
+		if len(reply.Errors) != 0 {
+			return fmt.Errorf("got errors %v", reply.Errors)
+		}
+	case 1:
+		commit := message.NewCommit()
+		reply, err := session.SyncRPCContext(context.Background(), commit, netconf.WithCallTimeout(5*time.Second))
+		if err != nil {
+			return err
+		}
+		if reply.MessageID != commit.GetMessageID() {
+			return fmt.Errorf("got reply %s to rpc %s", reply.MessageID, commit.GetMessageID())
+		}
+	case 2:
+		lock := message.NewLock(message.DatastoreCandidate)
+		replies := make(chan netconf.Event, 1)
+		if err := session.AsyncRPC(lock, func(e netconf.Event) { replies <- e }); err != nil {
+			return err
+		}
+		select {
+		case e := <-replies:
+			if e.Err() != nil || e.RPCReply().MessageID != lock.GetMessageID() {
+				return fmt.Errorf("got %v, wanted the reply to rpc %s", e.Err(), lock.GetMessageID())
+			}
+		case <-time.After(5 * time.Second):
+			return fmt.Errorf("reply to rpc %s not received", lock.GetMessageID())
+		}
+	case 3:
+		stream, err := session.StreamRPC(message.NewGet("", ""), 5)
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+		if _, err := io.Copy(io.Discard, stream); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestConcurrentNotificationStreams(t *testing.T) {
+	session, _ := newMemorySession(t)
+
+	const goroutines = 10
+	var wg sync.WaitGroup
+	var created atomic.Int32
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := session.CreateNotificationStream(5, "", "", "", func(netconf.Event) {}); err == nil {
+				created.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := created.Load(); n != 1 {
+		t.Errorf("got %d notification streams created, wanted 1", n)
+	}
+	if !session.NotificationStreamCreated() || !session.IsNotificationStreamCreated {
+		t.Errorf("notification stream not reported as created")
+	}
+}
+
+func TestConcurrentDispatcher(t *testing.T) {
+	session, _ := newMemorySession(t)
+
+	// Callbacks registered and removed by the callers race with the replies dispatched by the reader.
+	var wg sync.WaitGroup
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				id := fmt.Sprintf("unrelated-%d-%d", g, i)
+				session.Listener.Register(id, func(netconf.Event) {})
+				session.Listener.Remove(id)
+				if err := concurrentCall(session, 1); err != nil {
+					t.Error(err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentClose(t *testing.T) {
+	session, _ := newMemorySession(t)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				err := concurrentCall(session, (g+i)%4)
+				if errors.Is(err, netconf.ErrSessionClosed) {
+					return
+				}
+				if err != nil && !session.Closed() {
+					t.Errorf("got %v before the session was closed", err)
+					return
+				}
+			}
+		}(g)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	for g := 0; g < 3; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = session.Close()
+		}()
+	}
+	wg.Wait()
+
+	if !session.Closed() {
+		t.Errorf("session not closed")
+	}
+}
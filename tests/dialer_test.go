@@ -0,0 +1,320 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+	"golang.org/x/crypto/ssh"
+)
+
+// pipe copies data both ways between a and b, until either side is done.
+func pipe(a io.ReadWriteCloser, b io.ReadWriteCloser) {
+	done := make(chan struct{}, 2)
+	go func() { _, _ = io.Copy(a, b); done <- struct{}{} }()
+	go func() { _, _ = io.Copy(b, a); done <- struct{}{} }()
+	<-done
+	_ = a.Close()
+	_ = b.Close()
+}
+
+// bastion is an SSH server only forwarding direct-tcpip channels, like a jump host.
+type bastion struct {
+	addr   string
+	closed chan struct{}
+}
+
+func listenBastion(t *testing.T) *bastion {
+	config, _ := newSSHServerConfig(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	b := &bastion{addr: listener.Addr().String(), closed: make(chan struct{}, 10)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn, config)
+		}
+	}()
+	return b
+}
+
+func (b *bastion) serve(conn net.Conn, config *ssh.ServerConfig) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		_ = conn.Close()
+		b.closed <- struct{}{}
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		var payload struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+			_ = newChannel.Reject(ssh.Prohibited, "invalid payload")
+			continue
+		}
+		target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+		if err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		ch, requests, err := newChannel.Accept()
+		if err != nil {
+			_ = target.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		go pipe(ch, target)
+	}
+	b.closed <- struct{}{}
+}
+
+// waitClosed waits for the bastion to report count closed connections.
+func (b *bastion) waitClosed(t *testing.T, count int) {
+	for i := 0; i < count; i++ {
+		select {
+		case <-b.closed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("connection to bastion %s not closed", b.addr)
+		}
+	}
+}
+
+// listenSOCKS5 starts a SOCKS5 proxy, requiring username and password authentication when username is set.
+func listenSOCKS5(t *testing.T, username string, password string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS5(conn, username, password)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveSOCKS5(conn net.Conn, username string, password string) {
+	r := bufio.NewReader(conn)
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		_ = conn.Close()
+		return
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(r, methods); err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	if username != "" {
+		_, _ = conn.Write([]byte{5, 2})
+		auth := make([]byte, 2)
+		_, _ = io.ReadFull(r, auth)
+		user := make([]byte, auth[1])
+		_, _ = io.ReadFull(r, user)
+		length, _ := r.ReadByte()
+		pass := make([]byte, length)
+		_, _ = io.ReadFull(r, pass)
+		if string(user) != username || string(pass) != password {
+			_, _ = conn.Write([]byte{1, 1})
+			_ = conn.Close()
+			return
+		}
+		_, _ = conn.Write([]byte{1, 0})
+	} else {
+		_, _ = conn.Write([]byte{5, 0})
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(r, request); err != nil {
+		_ = conn.Close()
+		return
+	}
+	var host string
+	switch request[3] {
+	case 1:
+		ip := make([]byte, 4)
+		_, _ = io.ReadFull(r, ip)
+		host = net.IP(ip).String()
+	case 3:
+		length, _ := r.ReadByte()
+		name := make([]byte, length)
+		_, _ = io.ReadFull(r, name)
+		host = string(name)
+	}
+	port := make([]byte, 2)
+	_, _ = io.ReadFull(r, port)
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		_ = conn.Close()
+		return
+	}
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	pipe(conn, target)
+}
+
+// listenHTTPConnect starts an HTTP proxy supporting the CONNECT method, requiring basic authentication
+// when username is set.
+func listenHTTPConnect(t *testing.T, username string, password string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if username != "" {
+			want := "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+			if r.Header.Get("Proxy-Authorization") != want {
+				w.WriteHeader(http.StatusProxyAuthRequired)
+				return
+			}
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			_ = target.Close()
+			return
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		pipe(conn, target)
+	})}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+	return listener.Addr().String()
+}
+
+// netconfTarget starts an SSH NETCONF server answering every rpc with ok.
+func netconfTarget(t *testing.T) string {
+	config, _ := newSSHServerConfig(t)
+	return listenSSH(t, config, rpcHandler(1, okReply)).Addr().String()
+}
+
+func commit(t *testing.T, session *netconf.Session) {
+	reply, err := session.SyncRPC(message.NewCommit(), 5)
+	if err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	if len(reply.Errors) != 0 {
+		t.Errorf("got errors %v", reply.Errors)
+	}
+}
+
+func TestDialSSHViaJumpHosts(t *testing.T) {
+	target := netconfTarget(t)
+	first, second := listenBastion(t), listenBastion(t)
+
+	session, err := netconf.NewSessionFromSSHVia(nil, target, sshClientConfig(), []netconf.SSHJumpHost{
+		{Address: first.addr, Config: sshClientConfig()},
+		{Address: second.addr, Config: sshClientConfig()},
+	})
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	commit(t, session)
+
+	_ = session.Close()
+	first.waitClosed(t, 1)
+	second.waitClosed(t, 1)
+}
+
+func TestDialSSHViaJumpHostFailure(t *testing.T) {
+	target := netconfTarget(t)
+	first, second := listenBastion(t), listenBastion(t)
+
+	rejected := sshClientConfig()
+	rejected.Auth = []ssh.AuthMethod{ssh.Password("wrong")}
+	_, err := netconf.DialSSHVia(nil, target, sshClientConfig(),
+		netconf.SSHJumpHost{Address: first.addr, Config: sshClientConfig()},
+		netconf.SSHJumpHost{Address: second.addr, Config: rejected},
+	)
+	if err == nil {
+		t.Fatalf("dialed through a jump host rejecting the credentials")
+	}
+	// The connection to the first jump host must not leak.
+	first.waitClosed(t, 1)
+}
+
+func TestDialSSHViaSOCKS5(t *testing.T) {
+	target := netconfTarget(t)
+	proxy := listenSOCKS5(t, "user", "secret")
+
+	session, err := netconf.NewSessionFromSSHVia(
+		&netconf.SOCKS5Dialer{Address: proxy, Username: "user", Password: "secret"},
+		target, sshClientConfig(), nil,
+	)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	defer session.Close()
+	commit(t, session)
+
+	_, err = netconf.DialSSHVia(&netconf.SOCKS5Dialer{Address: proxy, Username: "user", Password: "wrong"}, target, sshClientConfig())
+	if !errors.Is(err, netconf.ErrProxy) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrProxy)
+	}
+}
+
+func TestDialSSHViaHTTPConnect(t *testing.T) {
+	target := netconfTarget(t)
+	jumpHost := listenBastion(t)
+	proxy := listenHTTPConnect(t, "user", "secret")
+
+	// The proxy is used to reach the jump host, which reaches the target.
+	session, err := netconf.NewSessionFromSSHVia(
+		&netconf.HTTPConnectDialer{Address: proxy, Username: "user", Password: "secret"},
+		target, sshClientConfig(), []netconf.SSHJumpHost{{Address: jumpHost.addr, Config: sshClientConfig()}},
+	)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	commit(t, session)
+	_ = session.Close()
+	jumpHost.waitClosed(t, 1)
+
+	_, err = netconf.DialSSHVia(&netconf.HTTPConnectDialer{Address: proxy}, target, sshClientConfig())
+	if !errors.Is(err, netconf.ErrProxy) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrProxy)
+	}
+}
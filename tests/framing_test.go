@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+)
+
+func TestReceiveChunked(t *testing.T) {
+	large := strings.Repeat("<interface><name>et-0/0/0</name></interface>", 50000)
+
+	tests := []struct {
+		name string
+		wire string
+		want []string
+	}{
+		{
+			name: "single chunk",
+			wire: "\n#6\n<ok/>\n\n##\n",
+			want: []string{"<ok/>\n"},
+		},
+		{
+			name: "multiple chunks",
+			wire: "\n#4\n<rpc\n#17\n-reply><ok/></rpc\n#7\n-reply>\n##\n",
+			want: []string{"<rpc-reply><ok/></rpc-reply>"},
+		},
+		{
+			name: "end-of-chunks marker inside chunk data",
+			wire: "\n#15\n<a>\n##\n</a>\n##\n\n##\n",
+			want: []string{"<a>\n##\n</a>\n##\n"},
+		},
+		{
+			name: "pipelined messages",
+			wire: "\n#5\n<a/>\n\n##\n\n#5\n<b/>\n\n##\n",
+			want: []string{"<a/>\n", "<b/>\n"},
+		},
+		{
+			name: "large message",
+			wire: fmt.Sprintf("\n#%d\n%s\n#1\n \n##\n", len(large), large),
+			want: []string{large + " "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := dialSSHPipe(t, rawHandler(tt.wire))
+			transport.SetVersion("v1.1")
+
+			for _, want := range tt.want {
+				got, err := transport.Receive()
+				if err != nil {
+					t.Fatalf("failed to receive: %v", err)
+				}
+				if string(got) != want {
+					t.Errorf("got %q, wanted %q", truncate(string(got)), truncate(want))
+				}
+			}
+			if _, err := transport.Receive(); err != io.EOF {
+				t.Errorf("got %v, wanted %v once the server is gone", err, io.EOF)
+			}
+		})
+	}
+}
+
+func TestReceiveChunkedErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		wire   string
+		offset int64
+	}{
+		{"missing newline", "#5\n<a/>\n\n##\n", 1},
+		{"missing hash", "\n5\n<a/>\n\n##\n", 2},
+		{"leading zero", "\n#05\n<a/>\n\n##\n", 3},
+		{"not a number", "\n#5a\n<a/>\n\n##\n", 4},
+		{"empty message", "\n##\n", 4},
+		{"chunk-size too large", "\n#4294967296\n", 13},
+		{"chunk-size too long", "\n#12345678901\n", 13},
+		{"garbage after chunk", "\n#5\n<a/>\nxx", 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := dialSSHPipe(t, rawHandler(tt.wire))
+			transport.SetVersion("v1.1")
+
+			_, err := transport.Receive()
+			if !errors.Is(err, netconf.ErrBadChunk) {
+				t.Fatalf("got %v, wanted %v", err, netconf.ErrBadChunk)
+			}
+			var chunkErr *netconf.ChunkError
+			if !errors.As(err, &chunkErr) || chunkErr.Offset != tt.offset {
+				t.Errorf("got %v, wanted error at offset %d", err, tt.offset)
+			}
+		})
+	}
+}
+
+func TestReceiveChunkedTruncated(t *testing.T) {
+	transport := dialSSHPipe(t, rawHandler("\n#10\n<a/>"))
+	transport.SetVersion("v1.1")
+
+	if _, err := transport.Receive(); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v, wanted %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestReceiveEndOfMessage(t *testing.T) {
+	transport := dialSSHPipe(t, rawHandler("<a/>]]>]]><b>]]></b>]]>]]>\n#5\n<c/>\n\n##\n<d/>"))
+
+	for _, want := range []string{"<a/>", "<b>]]></b>"} {
+		got, err := transport.Receive()
+		if err != nil {
+			t.Fatalf("failed to receive: %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("got %q, wanted %q", got, want)
+		}
+	}
+
+	// Switching framing must not lose the bytes already read ahead.
+	transport.SetVersion("v1.1")
+	got, err := transport.Receive()
+	if err != nil {
+		t.Fatalf("failed to receive: %v", err)
+	}
+	if string(got) != "<c/>\n" {
+		t.Errorf("got %q, wanted %q", got, "<c/>\n")
+	}
+
+	transport.SetVersion("v1.0")
+	if _, err := transport.Receive(); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v, wanted %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func truncate(s string) string {
+	if len(s) > 64 {
+		return s[:64] + "..."
+	}
+	return s
+}
@@ -0,0 +1,236 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+	"golang.org/x/crypto/ssh"
+)
+
+// clientFirstHandler waits for the client hello before sending its own hello, then answers every rpc
+// using the framing of the base version both advertised.
+func clientFirstHandler(hello string, clientHello chan<- *message.Hello) netconfHandler {
+	return func(ch ssh.Channel) {
+		r := bufio.NewReader(ch)
+		raw, err := readEOM(r)
+		if err != nil {
+			return
+		}
+		received := &message.Hello{}
+		if err := xml.Unmarshal([]byte(raw), received); err != nil {
+			return
+		}
+		clientHello <- received
+		if _, err := ch.Write([]byte(hello)); err != nil {
+			return
+		}
+
+		chunked := false
+		for _, capability := range received.Capabilities {
+			if capability == message.NetconfVersion11 && strings.Contains(hello, message.NetconfVersion11) {
+				chunked = true
+			}
+		}
+		for {
+			var request string
+			if chunked {
+				request, err = readChunked(r)
+			} else {
+				request, err = readEOM(r)
+			}
+			if err != nil {
+				return
+			}
+			reply := okReply(messageID(request), request)
+			if chunked {
+				err = writeChunked(ch, reply)
+			} else {
+				_, err = ch.Write([]byte(reply + eom))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestHelloNegotiatesHighestBaseVersion(t *testing.T) {
+	clientHello := make(chan *message.Hello, 1)
+	session := newTestSession(t, clientFirstHandler(serverHello(12), clientHello))
+	defer session.Close()
+
+	if session.SessionID != 12 {
+		t.Errorf("got session-id %d, wanted 12", session.SessionID)
+	}
+	if session.BaseVersion != message.NetconfVersion11 {
+		t.Errorf("got base version %q, wanted %q", session.BaseVersion, message.NetconfVersion11)
+	}
+	if len(session.Capabilities) != 2 {
+		t.Errorf("got server capabilities %v, wanted both base versions", session.Capabilities)
+	}
+	hello := <-clientHello
+	if len(hello.Capabilities) != len(netconf.DefaultCapabilities) {
+		t.Errorf("got client capabilities %v, wanted %v", hello.Capabilities, netconf.DefaultCapabilities)
+	}
+
+	reply, err := session.SyncRPC(message.NewCommit(), 5)
+	if err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	if len(reply.Errors) != 0 {
+		t.Errorf("got errors %v", reply.Errors)
+	}
+}
+
+func TestHelloClientCapabilities(t *testing.T) {
+	clientHello := make(chan *message.Hello, 1)
+	session := newTestSession(t, clientFirstHandler(serverHello(1), clientHello),
+		netconf.WithClientCapabilities(message.NetconfVersion10, "urn:example:vendor:1.0"),
+	)
+	defer session.Close()
+
+	if session.BaseVersion != message.NetconfVersion10 {
+		t.Errorf("got base version %q, wanted %q", session.BaseVersion, message.NetconfVersion10)
+	}
+	hello := <-clientHello
+	if len(hello.Capabilities) != 2 || hello.Capabilities[1] != "urn:example:vendor:1.0" {
+		t.Errorf("got client capabilities %v", hello.Capabilities)
+	}
+
+	// The server uses the end-of-message framing for the rest of the session.
+	if _, err := session.SyncRPC(message.NewCommit(), 5); err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+}
+
+func TestHelloNoCommonBaseVersion(t *testing.T) {
+	_, err := netconf.NewSession(dialSSHPipe(t, helloHandlerWith(serverHello(1, message.NetconfVersion11))),
+		netconf.WithClientCapabilities(message.NetconfVersion10),
+	)
+	if !errors.Is(err, netconf.ErrNoCommonBaseVersion) {
+		t.Fatalf("got %v, wanted %v", err, netconf.ErrNoCommonBaseVersion)
+	}
+	var versionErr *netconf.BaseVersionError
+	if !errors.As(err, &versionErr) {
+		t.Fatalf("got %T, wanted a *netconf.BaseVersionError", err)
+	}
+	if len(versionErr.ServerCapabilities) != 1 || versionErr.ServerCapabilities[0] != message.NetconfVersion11 {
+		t.Errorf("got server capabilities %v", versionErr.ServerCapabilities)
+	}
+}
+
+func TestHelloInvalid(t *testing.T) {
+	tests := map[string]string{
+		"zero session-id":    serverHello(0),
+		"missing session-id": strings.Replace(serverHello(1), "<session-id>1</session-id>", "", 1),
+	}
+	for name, hello := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := netconf.NewSession(dialSSHPipe(t, helloHandlerWith(hello)))
+			if !errors.Is(err, netconf.ErrInvalidHello) {
+				t.Fatalf("got %v, wanted %v", err, netconf.ErrInvalidHello)
+			}
+		})
+	}
+}
+
+func TestHelloTimeout(t *testing.T) {
+	// The server never sends its hello.
+	handler := func(ch ssh.Channel) {
+		_, _ = readEOM(bufio.NewReader(ch))
+		time.Sleep(5 * time.Second)
+	}
+
+	start := time.Now()
+	_, err := netconf.NewSession(dialSSHPipe(t, handler), netconf.WithHelloTimeout(200*time.Millisecond))
+	if !errors.Is(err, netconf.ErrHelloTimeout) {
+		t.Fatalf("got %v, wanted %v", err, netconf.ErrHelloTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("timeout took %s", elapsed)
+	}
+}
+
+// helloHandlerWith sends the provided hello and waits for the client hello.
+func helloHandlerWith(hello string) netconfHandler {
+	return func(ch ssh.Channel) {
+		if _, err := ch.Write([]byte(hello)); err != nil {
+			return
+		}
+		_, _ = readEOM(bufio.NewReader(ch))
+	}
+}
+
+func TestHelloVendorCapabilities(t *testing.T) {
+	const vendor = "http://example.com/netconf/capability/vendor:1.0"
+	clientHello := make(chan *message.Hello, 1)
+	session := newTestSession(t, clientFirstHandler(serverHello(1), clientHello),
+		netconf.WithVendorCapabilities(vendor, message.NetconfVersion11))
+	defer session.Close()
+
+	hello := <-clientHello
+	want := []string{message.NetconfVersion10, message.NetconfVersion11, vendor}
+	if strings.Join(hello.Capabilities, " ") != strings.Join(want, " ") {
+		t.Errorf("got client capabilities %v, wanted %v", hello.Capabilities, want)
+	}
+	commit(t, session)
+}
+
+func TestDeferredHello(t *testing.T) {
+	clientHello := make(chan *message.Hello, 1)
+	session := newTestSession(t, clientFirstHandler(serverHello(3), clientHello), netconf.WithDeferredHello())
+	defer session.Close()
+
+	select {
+	case <-clientHello:
+		t.Fatalf("hello sent before SendHello")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if session.SessionID != 0 {
+		t.Errorf("got session-id %d before the hello exchange", session.SessionID)
+	}
+
+	if err := session.SendHello(&message.Hello{Capabilities: []string{message.NetconfVersion10}}); err != nil {
+		t.Fatalf("failed to exchange hello: %v", err)
+	}
+	if hello := <-clientHello; len(hello.Capabilities) != 1 || hello.Capabilities[0] != message.NetconfVersion10 {
+		t.Errorf("got client capabilities %v, wanted %s", hello.Capabilities, message.NetconfVersion10)
+	}
+	if session.SessionID != 3 || session.BaseVersion != message.NetconfVersion10 {
+		t.Errorf("got session-id %d and base version %q", session.SessionID, session.BaseVersion)
+	}
+	commit(t, session)
+
+	// The hello exchange is only performed once.
+	if err := session.SendHello(&message.Hello{}); err != nil {
+		t.Errorf("got %v, wanted SendHello to do nothing", err)
+	}
+	if err := session.SendHello(&message.Hello{Capabilities: []string{message.NetconfVersion10}}); err != nil {
+		t.Errorf("got %v, wanted SendHello to do nothing", err)
+	}
+}
+
+func TestSendHelloAfterExchange(t *testing.T) {
+	session := newTestSession(t, rpcHandler(1, okReply))
+	defer session.Close()
+
+	// The capabilities already advertised, in any order, are accepted.
+	advertised := append([]string(nil), session.ClientCapabilities...)
+	slices.Reverse(advertised)
+	if err := session.SendHello(&message.Hello{Capabilities: advertised}); err != nil {
+		t.Errorf("got %v, wanted SendHello to do nothing", err)
+	}
+	// Other capabilities can no longer be advertised.
+	err := session.SendHello(&message.Hello{Capabilities: []string{message.NetconfVersion10, "urn:example:vendor"}})
+	if !errors.Is(err, netconf.ErrHelloExchanged) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrHelloExchanged)
+	}
+	commit(t, session)
+}
@@ -0,0 +1,258 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newHostKey(t *testing.T) ssh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	return signer
+}
+
+func writeKnownHosts(t *testing.T, lines ...string) string {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write known_hosts: %v", err)
+	}
+	return path
+}
+
+func TestKnownHostsCallback(t *testing.T) {
+	const hostname = "device.example.com:830"
+	remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 830}
+	known, other, revoked := newHostKey(t).PublicKey(), newHostKey(t).PublicKey(), newHostKey(t).PublicKey()
+	host := knownhosts.Normalize(hostname)
+
+	tests := map[string]struct {
+		lines []string
+		key   ssh.PublicKey
+		want  error
+	}{
+		"known": {
+			lines: []string{knownhosts.Line([]string{host}, known)},
+			key:   known,
+		},
+		"hashed": {
+			lines: []string{knownhosts.Line([]string{knownhosts.HashHostname(host)}, known)},
+			key:   known,
+		},
+		"mismatch": {
+			lines: []string{knownhosts.Line([]string{host}, known)},
+			key:   other,
+			want:  netconf.ErrHostKeyMismatch,
+		},
+		"unknown": {
+			lines: []string{knownhosts.Line([]string{"[other.example.com]:830"}, known)},
+			key:   known,
+			want:  netconf.ErrHostKeyUnknown,
+		},
+		"revoked": {
+			lines: []string{knownhosts.Line([]string{host}, revoked), "@revoked * " + string(ssh.MarshalAuthorizedKey(revoked))},
+			key:   revoked,
+			want:  netconf.ErrHostKeyRevoked,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			callback, err := netconf.KnownHostsCallback(writeKnownHosts(t, test.lines...))
+			if err != nil {
+				t.Fatalf("failed to load known_hosts: %v", err)
+			}
+			err = callback(hostname, remote, test.key)
+			if test.want == nil {
+				if err != nil {
+					t.Fatalf("got %v, wanted the key to be accepted", err)
+				}
+				return
+			}
+			if !errors.Is(err, test.want) {
+				t.Fatalf("got %v, wanted %v", err, test.want)
+			}
+			var hostKeyErr *netconf.HostKeyError
+			if !errors.As(err, &hostKeyErr) || hostKeyErr.Hostname != hostname {
+				t.Errorf("got %#v, wanted a *netconf.HostKeyError for %s", err, hostname)
+			}
+		})
+	}
+}
+
+func TestKnownHostsCertAuthority(t *testing.T) {
+	ca, hostKey := newHostKey(t), newHostKey(t)
+	cert := &ssh.Certificate{
+		Key:             hostKey.PublicKey(),
+		CertType:        ssh.HostCert,
+		ValidPrincipals: []string{"127.0.0.1"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatalf("failed to sign certificate: %v", err)
+	}
+	certSigner, err := ssh.NewCertSigner(cert, hostKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate signer: %v", err)
+	}
+
+	config, _ := newSSHServerConfig(t)
+	config.AddHostKey(certSigner)
+	address := listenSSH(t, config, rpcHandler(1, okReply)).Addr().String()
+
+	callback, err := netconf.KnownHostsCallback(writeKnownHosts(t,
+		"@cert-authority "+knownhosts.Normalize(address)+" "+string(ssh.MarshalAuthorizedKey(ca.PublicKey())),
+	))
+	if err != nil {
+		t.Fatalf("failed to load known_hosts: %v", err)
+	}
+	clientConfig := sshClientConfig()
+	clientConfig.HostKeyCallback = callback
+	clientConfig.HostKeyAlgorithms = []string{ssh.CertAlgoED25519v01}
+
+	session, err := netconf.NewSessionFromSSHConfig(address, clientConfig)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	defer session.Close()
+	commit(t, session)
+
+	// A certificate signed by another authority is rejected.
+	err = callback(address, nil, &ssh.Certificate{Key: hostKey.PublicKey(), CertType: ssh.HostCert, SignatureKey: hostKey.PublicKey()})
+	if !errors.Is(err, netconf.ErrHostCertificateInvalid) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrHostCertificateInvalid)
+	}
+}
+
+func TestPinnedHostKeyCallback(t *testing.T) {
+	pinned, other := newHostKey(t).PublicKey(), newHostKey(t).PublicKey()
+	callback := netconf.PinnedHostKeyCallback(map[string][]string{
+		"192.0.2.1":          {ssh.FingerprintSHA256(pinned)},
+		"device.example.com": {ssh.FingerprintLegacyMD5(pinned)},
+	})
+
+	if err := callback("192.0.2.1:830", nil, pinned); err != nil {
+		t.Errorf("got %v, wanted the pinned key to be accepted", err)
+	}
+	if err := callback("device.example.com:830", nil, pinned); err != nil {
+		t.Errorf("got %v, wanted the pinned key to be accepted using its MD5 fingerprint", err)
+	}
+	if err := callback("192.0.2.1:830", nil, other); !errors.Is(err, netconf.ErrHostKeyMismatch) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrHostKeyMismatch)
+	}
+	if err := callback("192.0.2.2:830", nil, pinned); !errors.Is(err, netconf.ErrHostKeyUnknown) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrHostKeyUnknown)
+	}
+}
+
+func TestTOFUHostKeyCallback(t *testing.T) {
+	for _, hashed := range []bool{false, true} {
+		store := netconf.NewFileHostKeyStore(filepath.Join(t.TempDir(), "known_hosts"))
+		store.HashHostnames = hashed
+		callback := netconf.TOFUHostKeyCallback(store)
+		remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 830}
+		first, other := newHostKey(t).PublicKey(), newHostKey(t).PublicKey()
+
+		if err := callback("192.0.2.1:830", remote, first); err != nil {
+			t.Fatalf("got %v, wanted the first key to be trusted", err)
+		}
+		if err := callback("192.0.2.1:830", remote, first); err != nil {
+			t.Errorf("got %v, wanted the recorded key to be accepted", err)
+		}
+		if err := callback("192.0.2.1:830", remote, other); !errors.Is(err, netconf.ErrHostKeyMismatch) {
+			t.Errorf("got %v, wanted %v", err, netconf.ErrHostKeyMismatch)
+		}
+		if err := callback("192.0.2.2:830", remote, other); err != nil {
+			t.Errorf("got %v, wanted another host to be trusted", err)
+		}
+
+		// The file is usable as an OpenSSH known_hosts file.
+		knownHosts, err := netconf.KnownHostsCallback(store.Path)
+		if err != nil {
+			t.Fatalf("failed to load known_hosts: %v", err)
+		}
+		if err := knownHosts("192.0.2.1:830", remote, first); err != nil {
+			t.Errorf("got %v, wanted the recorded key to be accepted", err)
+		}
+	}
+}
+
+// memoryHostKeyStore shows the store of TOFUHostKeyCallback can be swapped out.
+type memoryHostKeyStore map[string][]ssh.PublicKey
+
+func (s memoryHostKeyStore) Lookup(hostname string, _ net.Addr) ([]ssh.PublicKey, error) {
+	return s[hostname], nil
+}
+
+func (s memoryHostKeyStore) Add(hostname string, _ net.Addr, key ssh.PublicKey) error {
+	s[hostname] = append(s[hostname], key)
+	return nil
+}
+
+func TestTOFUHostKeyCallbackConcurrent(t *testing.T) {
+	store := memoryHostKeyStore{}
+	callback := netconf.TOFUHostKeyCallback(store)
+	remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 830}
+
+	// Concurrent first connections presenting different keys: only one of them is trusted.
+	const connections = 8
+	var wg sync.WaitGroup
+	var trusted atomic.Int32
+	for i := 0; i < connections; i++ {
+		key := newHostKey(t).PublicKey()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := callback("192.0.2.1:830", remote, key)
+			switch {
+			case err == nil:
+				trusted.Add(1)
+			case !errors.Is(err, netconf.ErrHostKeyMismatch):
+				t.Errorf("got %v, wanted %v", err, netconf.ErrHostKeyMismatch)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := trusted.Load(); n != 1 {
+		t.Errorf("got %d keys trusted, wanted 1", n)
+	}
+	if keys := store["192.0.2.1:830"]; len(keys) != 1 {
+		t.Errorf("got %d keys recorded, wanted 1", len(keys))
+	}
+}
+
+func TestTOFUHostKeyCallbackSession(t *testing.T) {
+	config, signer := newSSHServerConfig(t)
+	address := listenSSH(t, config, rpcHandler(1, okReply)).Addr().String()
+
+	store := memoryHostKeyStore{}
+	clientConfig := sshClientConfig()
+	clientConfig.HostKeyCallback = netconf.TOFUHostKeyCallback(store)
+	for i := 0; i < 2; i++ {
+		session, err := netconf.NewSessionFromSSHConfig(address, clientConfig)
+		if err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+		_ = session.Close()
+	}
+	if keys := store[address]; len(keys) != 1 || ssh.FingerprintSHA256(keys[0]) != ssh.FingerprintSHA256(signer.PublicKey()) {
+		t.Errorf("got %v recorded, wanted the server host key", keys)
+	}
+}
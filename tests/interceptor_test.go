@@ -0,0 +1,220 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+)
+
+func waitEvent(t *testing.T, events <-chan netconf.Event) netconf.Event {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatalf("event not received")
+		return nil
+	}
+}
+
+func TestRPCInterceptors(t *testing.T) {
+	requests := make(chan string, 2)
+	var mu sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+
+	audit := func(ctx context.Context, info *netconf.RPCInfo, invoker netconf.RPCInvoker) (*message.RPCReply, error) {
+		record(fmt.Sprintf("audit %T", info.Operation))
+		reply, err := invoker(ctx, info)
+		if err == nil {
+			record("audit " + reply.MessageID)
+		}
+		return reply, err
+	}
+	vendor := func(ctx context.Context, info *netconf.RPCInfo, invoker netconf.RPCInvoker) (*message.RPCReply, error) {
+		record("vendor")
+		info.Request = []byte(strings.Replace(string(info.Request), "<rpc ", `<rpc xmlns:v="urn:example:vendor" v:tag="abc" `, 1))
+		return invoker(ctx, info)
+	}
+	session := newTestSession(t, rpcHandler(1, func(messageID, request string) string {
+		requests <- request
+		return okReply(messageID, request)
+	}), netconf.WithRPCInterceptors(audit, vendor))
+	defer session.Close()
+
+	commit := message.NewCommit()
+	if _, err := session.SyncRPC(commit, 5); err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	lock := message.NewLock(message.DatastoreCandidate)
+	replies := make(chan netconf.Event, 1)
+	if err := session.AsyncRPC(lock, func(e netconf.Event) { replies <- e }); err != nil {
+		t.Fatalf("failed to send rpc: %v", err)
+	}
+	if e := waitEvent(t, replies); e.Err() != nil || e.RPCReply().MessageID != lock.GetMessageID() {
+		t.Errorf("got %v, wanted the reply to the lock", e.Err())
+	}
+
+	for i := 0; i < 2; i++ {
+		if request := <-requests; !strings.Contains(request, `v:tag="abc"`) {
+			t.Errorf("got %s, wanted the vendor attribute", request)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{
+		"audit *message.Commit", "vendor", "audit " + commit.GetMessageID(),
+		"audit *message.Lock", "vendor", "audit " + lock.GetMessageID(),
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got calls %q, wanted %q", calls, want)
+	}
+}
+
+func TestRPCInterceptorsFaultInjection(t *testing.T) {
+	errInjected := errors.New("injected fault")
+	requests := make(chan string, 10)
+	faults := func(ctx context.Context, info *netconf.RPCInfo, invoker netconf.RPCInvoker) (*message.RPCReply, error) {
+		switch info.Operation.(type) {
+		case *message.Commit:
+			// Fail without sending the request.
+			return nil, errInjected
+		case *message.Lock:
+			// Reply without sending the request.
+			return &message.RPCReply{MessageID: info.Operation.GetMessageID()}, nil
+		case *message.Unlock:
+			// Fail once the reply is received.
+			if _, err := invoker(ctx, info); err != nil {
+				return nil, err
+			}
+			return nil, errInjected
+		}
+		return invoker(ctx, info)
+	}
+	session := newTestSession(t, rpcHandler(1, func(messageID, request string) string {
+		requests <- request
+		return okReply(messageID, request)
+	}), netconf.WithRPCInterceptors(faults))
+	defer session.Close()
+
+	if _, err := session.SyncRPC(message.NewCommit(), 5); !errors.Is(err, errInjected) {
+		t.Errorf("got %v, wanted %v", err, errInjected)
+	}
+	called := make(chan netconf.Event, 1)
+	if err := session.AsyncRPC(message.NewCommit(), func(e netconf.Event) { called <- e }); !errors.Is(err, errInjected) {
+		t.Errorf("got %v, wanted %v", err, errInjected)
+	}
+
+	lock := message.NewLock(message.DatastoreCandidate)
+	replies := make(chan netconf.Event, 1)
+	if err := session.AsyncRPC(lock, func(e netconf.Event) { replies <- e }); err != nil {
+		t.Fatalf("failed to send rpc: %v", err)
+	}
+	if e := waitEvent(t, replies); e.Err() != nil || e.RPCReply().MessageID != lock.GetMessageID() {
+		t.Errorf("got %v, wanted the injected reply", e.Err())
+	}
+
+	if err := session.AsyncRPC(message.NewUnlock(message.DatastoreCandidate), func(e netconf.Event) { replies <- e }); err != nil {
+		t.Fatalf("failed to send rpc: %v", err)
+	}
+	if e := waitEvent(t, replies); !errors.Is(e.Err(), errInjected) || e.RPCReply() != nil {
+		t.Errorf("got %v, wanted %v", e.Err(), errInjected)
+	}
+
+	// Only the unlock was sent.
+	if request := <-requests; !strings.Contains(request, "<unlock>") {
+		t.Errorf("got %s, wanted the unlock", request)
+	}
+	select {
+	case request := <-requests:
+		t.Errorf("got unexpected request %s", request)
+	case e := <-called:
+		t.Errorf("got %v, wanted the callback not to be invoked", e)
+	default:
+	}
+	waitStats(t, session, func(s netconf.Stats) bool { return s.InFlight == 0 && s.Sent == 1 })
+}
+
+func TestRPCInterceptorsRetry(t *testing.T) {
+	// Fall back to the running datastore, the server not supporting the candidate one.
+	fallback := func(ctx context.Context, info *netconf.RPCInfo, invoker netconf.RPCInvoker) (*message.RPCReply, error) {
+		reply, err := invoker(ctx, info)
+		var capabilityErr *message.CapabilityError
+		if errors.As(err, &capabilityErr) {
+			info.Operation = message.NewLock(message.DatastoreRunning)
+			info.Request = nil
+			return invoker(ctx, info)
+		}
+		return reply, err
+	}
+	session := newTestSession(t, rpcHandler(1, okReply), netconf.WithCapabilityCheck(), netconf.WithRPCInterceptors(fallback))
+	defer session.Close()
+
+	called := make(chan netconf.Event, 1)
+	err := session.AsyncRPC(message.NewLock(message.DatastoreCandidate), func(e netconf.Event) { called <- e })
+	var capabilityErr *message.CapabilityError
+	if !errors.As(err, &capabilityErr) {
+		t.Fatalf("got %v, wanted a *message.CapabilityError", err)
+	}
+
+	// The retry is sent, but AsyncRPC returned the error of the first attempt: the callback must not be invoked.
+	waitStats(t, session, func(s netconf.Stats) bool { return s.InFlight == 0 && s.Sent == 1 })
+	select {
+	case e := <-called:
+		t.Errorf("got %v, wanted the callback not to be invoked", e)
+	default:
+	}
+}
+
+func TestNotificationInterceptors(t *testing.T) {
+	var mu sync.Mutex
+	seen := 0
+	count := func(notification *message.Notification, handler netconf.NotificationHandler) {
+		mu.Lock()
+		seen++
+		mu.Unlock()
+		notification.EventData = "intercepted"
+		handler(notification)
+	}
+	dropped := false
+	dropFirst := func(notification *message.Notification, handler netconf.NotificationHandler) {
+		if !dropped {
+			dropped = true
+			return
+		}
+		handler(notification)
+	}
+	session, _ := newMemorySession(t, netconf.WithNotificationInterceptors(count, dropFirst))
+
+	notifications := make(chan netconf.Event, 2)
+	if err := session.CreateNotificationStream(5, "", "", "", func(e netconf.Event) { notifications <- e }); err != nil {
+		t.Fatalf("failed to create notification stream: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := session.SyncRPC(message.NewRPC("<trigger/>"), 5); err != nil {
+			t.Fatalf("failed to execute rpc: %v", err)
+		}
+	}
+
+	if e := waitEvent(t, notifications); e.Notification() == nil || e.Notification().EventData != "intercepted" {
+		t.Errorf("got %v, wanted the intercepted notification", e.Value())
+	}
+	if len(notifications) != 0 {
+		t.Errorf("got %d more notifications, wanted the first one dropped", len(notifications))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if seen != 2 {
+		t.Errorf("got %d notifications intercepted, wanted 2", seen)
+	}
+}
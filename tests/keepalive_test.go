@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"golang.org/x/crypto/ssh"
+)
+
+// ignoreRequests receives the global requests without ever replying, like a peer which stopped responding.
+func ignoreRequests(reqs <-chan *ssh.Request) {
+	for range reqs {
+	}
+}
+
+func TestKeepaliveDeadPeer(t *testing.T) {
+	config, _ := newSSHServerConfig(t)
+	serverConn, clientConn := socketPair(t)
+	go serveSSHWith(serverConn, config, rpcHandler(1, okReply), ignoreRequests)
+
+	c, chans, reqs, err := ssh.NewClientConn(clientConn, "pipe", sshClientConfig())
+	if err != nil {
+		t.Fatalf("failed to establish ssh connection: %v", err)
+	}
+	transport, err := netconf.NoDialSSH(ssh.NewClient(c, chans, reqs))
+	if err != nil {
+		t.Fatalf("failed to create transport: %v", err)
+	}
+	session, err := netconf.NewSession(transport, netconf.WithKeepalive(netconf.KeepaliveConfig{
+		Interval:  50 * time.Millisecond,
+		MaxMissed: 2,
+	}))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for transport.Err() == nil || !session.Closed() {
+		if time.Now().After(deadline) {
+			t.Fatalf("dead peer not detected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !errors.Is(transport.Err(), netconf.ErrPeerUnresponsive) {
+		t.Errorf("got %v, wanted %v", transport.Err(), netconf.ErrPeerUnresponsive)
+	}
+}
+
+func TestKeepaliveIdleSession(t *testing.T) {
+	config, _ := newSSHServerConfig(t)
+	listener := listenSSH(t, config, rpcHandler(1, okReply))
+
+	transport, err := netconf.DialSSHTimeout(listener.Addr().String(), sshClientConfig(), 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	session, err := netconf.NewSession(transport)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	defer session.Close()
+
+	// Staying idle longer than the timeout must not close the session, as long as the peer is alive.
+	time.Sleep(500 * time.Millisecond)
+	commit(t, session)
+	if err := transport.Err(); err != nil {
+		t.Errorf("got %v, wanted a live connection", err)
+	}
+}
+
+func TestKeepaliveTimeoutFactory(t *testing.T) {
+	config, _ := newSSHServerConfig(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			serveSSHWith(conn, config, rpcHandler(1, okReply), ignoreRequests)
+		}
+	}()
+
+	// The keepalive started by DialSSHTimeout, every 5 seconds, is replaced by the configured one.
+	session, err := netconf.NewSessionFromSSHConfigTimeout(context.Background(), listener.Addr().String(), sshClientConfig(),
+		10*time.Second, netconf.WithKeepalive(netconf.KeepaliveConfig{Interval: 50 * time.Millisecond, MaxMissed: 2}))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	defer session.Close()
+	transport := session.Transport.(*netconf.TransportSSH)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for transport.Err() == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("dead peer not detected using the configured keepalive")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !errors.Is(transport.Err(), netconf.ErrPeerUnresponsive) {
+		t.Errorf("got %v, wanted %v", transport.Err(), netconf.ErrPeerUnresponsive)
+	}
+}
@@ -0,0 +1,200 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+	"golang.org/x/crypto/ssh"
+)
+
+// heldHandler completes the hello exchange, then answers every rpc with <ok/> once release is closed.
+func heldHandler(release <-chan struct{}) netconfHandler {
+	return func(ch ssh.Channel) {
+		if _, err := ch.Write([]byte(serverHello(1))); err != nil {
+			return
+		}
+		r := bufio.NewReader(ch)
+		if _, err := readEOM(r); err != nil {
+			return
+		}
+		for {
+			request, err := readChunked(r)
+			if err != nil {
+				return
+			}
+			<-release
+			if err := writeChunked(ch, okReply(messageID(request), request)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// waitForCallbacks fails the test when callbacks are still registered in the dispatcher.
+func waitForCallbacks(t *testing.T, session *netconf.Session) {
+	done := make(chan struct{})
+	go func() {
+		session.Listener.WaitForMessages()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Errorf("callbacks left registered")
+	}
+}
+
+func TestSyncRPCContextDeadline(t *testing.T) {
+	release := make(chan struct{})
+	session := newTestSession(t, heldHandler(release))
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := session.SyncRPCContext(ctx, message.NewCommit()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, wanted %v", err, context.DeadlineExceeded)
+	}
+	waitForCallbacks(t, session)
+
+	// The late reply is discarded, and the session is still usable.
+	close(release)
+	reply, err := session.SyncRPCContext(context.Background(), message.NewCommit(), netconf.WithCallTimeout(5*time.Second))
+	if err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	if len(reply.Errors) != 0 {
+		t.Errorf("got errors %v", reply.Errors)
+	}
+}
+
+func TestSyncRPCContextCancel(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	session := newTestSession(t, heldHandler(release))
+	defer session.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if _, err := session.SyncRPCContext(ctx, message.NewCommit()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, wanted %v", err, context.Canceled)
+	}
+
+	// A context already done does not send anything.
+	if _, err := session.SyncRPCContext(ctx, message.NewCommit()); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, wanted %v", err, context.Canceled)
+	}
+	waitForCallbacks(t, session)
+}
+
+func TestSyncRPCCallTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	session := newTestSession(t, heldHandler(release))
+	defer session.Close()
+
+	start := time.Now()
+	_, err := session.SyncRPCContext(context.Background(), message.NewCommit(), netconf.WithCallTimeout(100*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, wanted %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("timed out after %s", elapsed)
+	}
+
+	// The legacy API no longer leaves the callback registered.
+	if _, err := session.SyncRPC(message.NewCommit(), 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, wanted %v", err, context.DeadlineExceeded)
+	}
+	waitForCallbacks(t, session)
+}
+
+func TestAsyncRPCContextCancel(t *testing.T) {
+	release := make(chan struct{})
+	session := newTestSession(t, heldHandler(release))
+	defer session.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	called := make(chan struct{}, 1)
+	if err := session.AsyncRPCContext(ctx, message.NewCommit(), func(netconf.Event) { called <- struct{}{} }); err != nil {
+		t.Fatalf("failed to send rpc: %v", err)
+	}
+	cancel()
+	waitForCallbacks(t, session)
+
+	close(release)
+	replied := make(chan struct{}, 1)
+	if err := session.AsyncRPCContext(context.Background(), message.NewCommit(), func(netconf.Event) { replied <- struct{}{} }); err != nil {
+		t.Fatalf("failed to send rpc: %v", err)
+	}
+	select {
+	case <-replied:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no reply received")
+	}
+	select {
+	case <-called:
+		t.Errorf("callback invoked after cancellation")
+	default:
+	}
+}
+
+func TestCreateNotificationStreamContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	session := newTestSession(t, heldHandler(release))
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := session.CreateNotificationStreamContext(ctx, "", "", "", func(netconf.Event) {})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, wanted %v", err, context.DeadlineExceeded)
+	}
+	if session.NotificationStreamCreated() || session.IsNotificationStreamCreated {
+		t.Errorf("notification stream reported as created")
+	}
+	waitForCallbacks(t, session)
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestCallLogAttrs(t *testing.T) {
+	var logs syncBuffer
+	session := newTestSession(t, rpcHandler(1, okReply), netconf.WithSessionLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	defer session.Close()
+
+	commit := message.NewCommit()
+	if _, err := session.SyncRPCContext(context.Background(), commit, netconf.WithCallLogAttrs("requestID", "abcd")); err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	for _, want := range []string{`"requestID":"abcd"`, `"messageID":"` + commit.GetMessageID() + `"`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("got logs %s, wanted %s", logs.String(), want)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	eventNotification = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>2024-05-01T10:00:00Z</eventTime><event/></notification>`
+	pushNotification  = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>2024-05-01T10:00:00Z</eventTime><push-update xmlns="urn:ietf:params:xml:ns:yang:ietf-yang-push"><id>7</id></push-update></notification>`
+)
+
+// flakyServer is a NETCONF server which can be taken down, and whose connections can be dropped.
+type flakyServer struct {
+	address string
+	// down makes the server close every new channel before the hello exchange.
+	down atomic.Bool
+	// hangup drops the connection receiving from it.
+	hangup chan struct{}
+	// subscriptions counts the create-subscription and establish-subscription requests.
+	subscriptions atomic.Int32
+}
+
+func listenFlaky(t *testing.T) *flakyServer {
+	s := &flakyServer{hangup: make(chan struct{})}
+	config, _ := newSSHServerConfig(t)
+	s.address = listenSSH(t, config, s.handle).Addr().String()
+	return s
+}
+
+// handle answers every rpc with <ok/>, except establish-subscription which is answered with a subscription
+// id. A <trigger/> rpc makes the server send a notification for both kinds of subscription.
+func (s *flakyServer) handle(ch ssh.Channel) {
+	if s.down.Load() {
+		return
+	}
+	if _, err := ch.Write([]byte(serverHello(1))); err != nil {
+		return
+	}
+	r := bufio.NewReader(ch)
+	if _, err := readEOM(r); err != nil {
+		return
+	}
+	requests := make(chan string)
+	go func() {
+		defer close(requests)
+		for {
+			request, err := readChunked(r)
+			if err != nil {
+				return
+			}
+			requests <- request
+		}
+	}()
+
+	for {
+		select {
+		case <-s.hangup:
+			return
+		case request, ok := <-requests:
+			if !ok {
+				return
+			}
+			reply := okReply(messageID(request), request)
+			switch {
+			case strings.Contains(request, "create-subscription"):
+				s.subscriptions.Add(1)
+			case strings.Contains(request, "establish-subscription"):
+				s.subscriptions.Add(1)
+				reply = fmt.Sprintf(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%s"><id xmlns="urn:ietf:params:xml:ns:yang:ietf-subscribed-notifications">7</id></rpc-reply>`, messageID(request))
+			}
+			if err := writeChunked(ch, reply); err != nil {
+				return
+			}
+			if strings.Contains(request, "<trigger/>") {
+				_ = writeChunked(ch, eventNotification)
+				_ = writeChunked(ch, pushNotification)
+			}
+		}
+	}
+}
+
+func newReconnectingSession(t *testing.T, server *flakyServer, config netconf.ReconnectConfig) (*netconf.ReconnectingSession, <-chan netconf.StateChange) {
+	changes := make(chan netconf.StateChange, 100)
+	config.InitialBackoff = 10 * time.Millisecond
+	config.MaxBackoff = 50 * time.Millisecond
+	config.OnStateChange = func(change netconf.StateChange) { changes <- change }
+
+	r, err := netconf.NewReconnectingSessionFromSSHConfig(context.Background(), server.address, sshClientConfig(), config)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+	return r, changes
+}
+
+// waitState waits for the provided state, returning the changes received until then.
+func waitState(t *testing.T, changes <-chan netconf.StateChange, state netconf.ConnectionState) []netconf.StateChange {
+	var received []netconf.StateChange
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case change := <-changes:
+			received = append(received, change)
+			if change.State == state {
+				return received
+			}
+		case <-timeout:
+			t.Fatalf("got %v, wanted state %s", received, state)
+		}
+	}
+}
+
+func waitNotifications(t *testing.T, events <-chan netconf.Event, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case event := <-events:
+			if event.Err() != nil || event.Notification() == nil {
+				t.Errorf("got %v, wanted a notification", event.Err())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("notification not received")
+		}
+	}
+}
+
+func TestReconnectingSession(t *testing.T) {
+	server := listenFlaky(t)
+	r, changes := newReconnectingSession(t, server, netconf.ReconnectConfig{})
+	ctx := context.Background()
+
+	stream, push := make(chan netconf.Event, 10), make(chan netconf.Event, 10)
+	if err := r.CreateNotificationStream(ctx, "", "", "", func(e netconf.Event) { stream <- e }); err != nil {
+		t.Fatalf("failed to create notification stream: %v", err)
+	}
+	if err := r.EstablishSubscription(ctx, "<establish-subscription/>", func(e netconf.Event) { push <- e }); err != nil {
+		t.Fatalf("failed to establish subscription: %v", err)
+	}
+
+	first := r.Session()
+	server.hangup <- struct{}{}
+	received := waitState(t, changes, netconf.StateConnected)
+	if received[0].State != netconf.StateDisconnected || !errors.Is(received[0].Err, netconf.ErrSessionClosed) {
+		t.Errorf("got %+v, wanted the disconnection first", received[0])
+	}
+	if received[1].State != netconf.StateConnecting || received[1].Attempt != 1 {
+		t.Errorf("got %+v, wanted the first attempt", received[1])
+	}
+	if r.Session() == first || r.State() != netconf.StateConnected {
+		t.Fatalf("session not replaced")
+	}
+
+	// Both subscriptions were established again on the new session.
+	if got := server.subscriptions.Load(); got != 4 {
+		t.Errorf("got %d subscriptions, wanted 4", got)
+	}
+	reply, err := r.SyncRPC(ctx, message.NewRPC("<trigger/>"))
+	if err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	if len(reply.Errors) != 0 {
+		t.Errorf("got errors %v", reply.Errors)
+	}
+	waitNotifications(t, stream, 1)
+	waitNotifications(t, push, 1)
+
+	// The callbacks only receive a termination event once closed.
+	if err := r.Close(); err != nil {
+		t.Errorf("failed to close: %v", err)
+	}
+	select {
+	case <-r.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("session not closed")
+	}
+	for _, events := range []chan netconf.Event{stream, push} {
+		select {
+		case event := <-events:
+			if !errors.Is(event.Err(), netconf.ErrSessionClosed) {
+				t.Errorf("got %v, wanted a termination event", event.Err())
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("termination event not received")
+		}
+	}
+	if _, err := r.SyncRPC(ctx, message.NewCommit()); !errors.Is(err, netconf.ErrSessionClosed) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrSessionClosed)
+	}
+}
+
+func TestReconnectingSessionFailFast(t *testing.T) {
+	server := listenFlaky(t)
+	r, changes := newReconnectingSession(t, server, netconf.ReconnectConfig{Policy: netconf.FailFast})
+
+	server.down.Store(true)
+	server.hangup <- struct{}{}
+	waitState(t, changes, netconf.StateDisconnected)
+
+	if _, err := r.SyncRPC(context.Background(), message.NewCommit()); !errors.Is(err, netconf.ErrDisconnected) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrDisconnected)
+	}
+
+	server.down.Store(false)
+	waitState(t, changes, netconf.StateConnected)
+	if _, err := r.SyncRPC(context.Background(), message.NewCommit()); err != nil {
+		t.Errorf("failed to execute rpc: %v", err)
+	}
+}
+
+func TestReconnectingSessionQueue(t *testing.T) {
+	server := listenFlaky(t)
+	r, changes := newReconnectingSession(t, server, netconf.ReconnectConfig{Policy: netconf.Queue})
+
+	server.down.Store(true)
+	server.hangup <- struct{}{}
+	waitState(t, changes, netconf.StateDisconnected)
+
+	// Queued RPCs are bounded by their context.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := r.SyncRPC(ctx, message.NewCommit()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, wanted %v", err, context.DeadlineExceeded)
+	}
+
+	time.AfterFunc(100*time.Millisecond, func() { server.down.Store(false) })
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := r.SyncRPC(ctx, message.NewCommit()); err != nil {
+		t.Errorf("failed to execute queued rpc: %v", err)
+	}
+}
+
+func TestReconnectingSessionGiveUp(t *testing.T) {
+	server := listenFlaky(t)
+	r, changes := newReconnectingSession(t, server, netconf.ReconnectConfig{MaxAttempts: 3, Policy: netconf.Queue})
+
+	server.down.Store(true)
+	server.hangup <- struct{}{}
+	received := waitState(t, changes, netconf.StateClosed)
+
+	attempts := 0
+	for _, change := range received {
+		if change.State == netconf.StateConnecting {
+			attempts++
+		}
+	}
+	if attempts != 3 {
+		t.Errorf("got %d attempts, wanted 3", attempts)
+	}
+	select {
+	case <-r.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("session not closed")
+	}
+	if !errors.Is(r.Err(), netconf.ErrSessionClosed) || r.State() != netconf.StateClosed {
+		t.Errorf("got %v in state %s, wanted %v", r.Err(), r.State(), netconf.ErrSessionClosed)
+	}
+	if _, err := r.SyncRPC(context.Background(), message.NewCommit()); !errors.Is(err, netconf.ErrSessionClosed) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrSessionClosed)
+	}
+}
+
+func TestReconnectingSessionDialContext(t *testing.T) {
+	// The server accepts the connection, but never completes the SSH handshake.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = netconf.NewReconnectingSessionFromSSHConfig(ctx, listener.Addr().String(), sshClientConfig(), netconf.ReconnectConfig{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, wanted %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("dial returned after %s", elapsed)
+	}
+}
+
+func TestReconnectingSessionAttemptTimeout(t *testing.T) {
+	server := listenFlaky(t)
+	var connects atomic.Int32
+	_, changes := newReconnectingSession(t, server, netconf.ReconnectConfig{
+		AttemptTimeout: 50 * time.Millisecond,
+		// Every reconnection hangs until the attempt times out.
+		OnConnect: func(ctx context.Context, session *netconf.Session) error {
+			if connects.Add(1) == 1 {
+				return nil
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	server.hangup <- struct{}{}
+	waitState(t, changes, netconf.StateConnecting)
+	received := waitState(t, changes, netconf.StateDisconnected)
+	if change := received[len(received)-1]; change.Attempt != 1 || !errors.Is(change.Err, context.DeadlineExceeded) {
+		t.Errorf("got attempt %d failing with %v, wanted %v", change.Attempt, change.Err, context.DeadlineExceeded)
+	}
+}
+
+func TestConnectionStateString(t *testing.T) {
+	if s := netconf.StateConnecting.String(); s != "connecting" {
+		t.Errorf("got %q, wanted connecting", s)
+	}
+	for _, state := range []netconf.ConnectionState{-1, 42} {
+		if s, want := state.String(), fmt.Sprintf("ConnectionState(%d)", state); s != want {
+			t.Errorf("got %q, wanted %q", s, want)
+		}
+	}
+}
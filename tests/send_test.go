@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+	"golang.org/x/crypto/ssh"
+)
+
+type receivedRPC struct {
+	request string
+	chunks  []int
+}
+
+// recordingHandler behaves like rpcHandler, and reports every request received along with its chunk sizes.
+func recordingHandler(received chan<- receivedRPC) netconfHandler {
+	return func(ch ssh.Channel) {
+		if _, err := ch.Write([]byte(serverHello(1))); err != nil {
+			return
+		}
+		r := bufio.NewReader(ch)
+		if _, err := readEOM(r); err != nil {
+			return
+		}
+		for {
+			request, chunks, err := readChunks(r)
+			if err != nil {
+				return
+			}
+			received <- receivedRPC{request: request, chunks: chunks}
+			if err := writeChunked(ch, okReply(messageID(request), request)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestSendChunkSize(t *testing.T) {
+	received := make(chan receivedRPC, 1)
+	session := newTestSession(t, recordingHandler(received), netconf.WithChunkSize(100))
+	defer session.Close()
+
+	if _, err := session.SyncRPC(message.NewCommit(), 5); err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	rpc := <-received
+	for i, size := range rpc.chunks {
+		if size > 100 || (i < len(rpc.chunks)-1 && size != 100) {
+			t.Errorf("got chunk sizes %v, wanted chunks of 100 bytes", rpc.chunks)
+			break
+		}
+	}
+	if !strings.Contains(rpc.request, "<commit></commit>") {
+		t.Errorf("got %q, wanted a commit", rpc.request)
+	}
+}
+
+func TestSendHugeChunkSize(t *testing.T) {
+	received := make(chan receivedRPC, 1)
+	session := newTestSession(t, recordingHandler(received), netconf.WithChunkSize(math.MaxInt))
+	defer session.Close()
+
+	config := strings.Repeat("<user><name>admin</name></user>", 100)
+	rpc := message.NewEditConfigStream(message.DatastoreCandidate, message.DefaultOperationTypeMerge, strings.NewReader(config))
+	if _, err := session.SyncRPC(rpc, 5); err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	if got := <-received; len(got.chunks) != 1 {
+		t.Errorf("got chunk sizes %v, wanted a single chunk", got.chunks)
+	}
+}
+
+// failingReader returns the content of r, then fails with err.
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, f.err
+	}
+	return n, err
+}
+
+func TestSendStreamReaderError(t *testing.T) {
+	received := make(chan receivedRPC, 1)
+	session := newTestSession(t, recordingHandler(received), netconf.WithChunkSize(16))
+	defer session.Close()
+
+	errRead := errors.New("read failed")
+	config := &failingReader{r: strings.NewReader(strings.Repeat("<user><name>admin</name></user>", 10)), err: errRead}
+	rpc := message.NewEditConfigStream(message.DatastoreCandidate, message.DefaultOperationTypeMerge, config)
+	if _, err := session.SyncRPC(rpc, 5); !errors.Is(err, errRead) {
+		t.Fatalf("got %v, wanted %v", err, errRead)
+	}
+
+	select {
+	case <-session.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session not closed after a partially sent message")
+	}
+	if err := session.Err(); !errors.Is(err, errRead) {
+		t.Errorf("got %v, wanted the session closed by %v", err, errRead)
+	}
+}
+
+func TestSendDefaultSingleChunk(t *testing.T) {
+	received := make(chan receivedRPC, 1)
+	session := newTestSession(t, recordingHandler(received))
+	defer session.Close()
+
+	if _, err := session.SyncRPC(message.NewCommit(), 5); err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	if rpc := <-received; len(rpc.chunks) != 1 {
+		t.Errorf("got chunk sizes %v, wanted a single chunk", rpc.chunks)
+	}
+}
+
+func TestSendEditConfigStream(t *testing.T) {
+	received := make(chan receivedRPC, 1)
+	session := newTestSession(t, recordingHandler(received), netconf.WithChunkSize(1024))
+	defer session.Close()
+
+	config := "<top xmlns=\"http://example.com/schema/1.2/config\">" +
+		strings.Repeat("<user><name>admin</name></user>", 1000) + "</top>"
+	rpc := message.NewEditConfigStream(message.DatastoreCandidate, message.DefaultOperationTypeMerge, strings.NewReader(config))
+
+	reply, err := session.SyncRPC(rpc, 5)
+	if err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	if len(reply.Errors) != 0 {
+		t.Errorf("expected ok reply, got %s", reply.RawReply)
+	}
+
+	got := <-received
+	if len(got.chunks) < 2 {
+		t.Errorf("got chunk sizes %v, wanted several chunks", got.chunks)
+	}
+	for i, size := range got.chunks[:len(got.chunks)-1] {
+		if size != 1024 {
+			t.Errorf("got chunk %d of %d bytes, wanted 1024", i, size)
+		}
+	}
+
+	var edit struct {
+		XMLName   xml.Name `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 rpc"`
+		MessageID string   `xml:"message-id,attr"`
+		Target    struct {
+			Candidate *struct{} `xml:"candidate"`
+		} `xml:"edit-config>target"`
+		Operation string `xml:"edit-config>default-operation"`
+		Config    struct {
+			Data string `xml:",innerxml"`
+		} `xml:"edit-config>config"`
+	}
+	if err := xml.Unmarshal([]byte(got.request), &edit); err != nil {
+		t.Fatalf("failed to unmarshal edit-config: %v", err)
+	}
+	if edit.MessageID != rpc.MessageID || edit.Target.Candidate == nil || edit.Operation != "merge" || edit.Config.Data != config {
+		t.Errorf("got unexpected edit-config %.200s", got.request)
+	}
+}
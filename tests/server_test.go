@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	eom = "]]>]]>"
+)
+
+// serverHello returns a NETCONF 1.0 framed hello advertising the provided capabilities.
+func serverHello(sessionID int, capabilities ...string) string {
+	if len(capabilities) == 0 {
+		capabilities = []string{message.NetconfVersion10, message.NetconfVersion11}
+	}
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><capabilities>`)
+	for _, c := range capabilities {
+		fmt.Fprintf(&b, "<capability>%s</capability>", c)
+	}
+	fmt.Fprintf(&b, "</capabilities><session-id>%d</session-id></hello>%s", sessionID, eom)
+	return b.String()
+}
+
+// readEOM reads a NETCONF 1.0 framed message.
+func readEOM(r *bufio.Reader) (string, error) {
+	var b bytes.Buffer
+	for {
+		line, err := r.ReadString('>')
+		b.WriteString(line)
+		if err != nil {
+			return b.String(), err
+		}
+		if bytes.HasSuffix(b.Bytes(), []byte(eom)) {
+			return b.String()[:b.Len()-len(eom)], nil
+		}
+	}
+}
+
+// readChunked reads a NETCONF 1.1 framed message.
+func readChunked(r *bufio.Reader) (string, error) {
+	msg, _, err := readChunks(r)
+	return msg, err
+}
+
+// readChunks reads a NETCONF 1.1 framed message, and returns the size of every chunk.
+func readChunks(r *bufio.Reader) (string, []int, error) {
+	var b bytes.Buffer
+	var sizes []int
+	for {
+		if _, err := r.Discard(2); err != nil { // "\n#"
+			return b.String(), sizes, err
+		}
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return b.String(), sizes, err
+		}
+		if header == "#\n" {
+			return b.String(), sizes, nil
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(header, "\n"))
+		if err != nil {
+			return b.String(), sizes, err
+		}
+		if _, err := io.CopyN(&b, r, int64(size)); err != nil {
+			return b.String(), sizes, err
+		}
+		sizes = append(sizes, size)
+	}
+}
+
+// writeChunked writes a NETCONF 1.1 framed message as a single chunk.
+func writeChunked(w io.Writer, msg string) error {
+	_, err := fmt.Fprintf(w, "\n#%d\n%s\n##\n", len(msg), msg)
+	return err
+}
+
+var errAuthFailed = errors.New("authentication failed")
+
+var messageIDRegex = regexp.MustCompile(`message-id="([^"]*)"`)
+
+// messageID extracts the message-id attribute of a request.
+func messageID(request string) string {
+	if m := messageIDRegex.FindStringSubmatch(request); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// okReply returns an rpc-reply holding <ok/>.
+func okReply(messageID, request string) string {
+	return fmt.Sprintf(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%s"><ok/></rpc-reply>`, messageID)
+}
+
+// rpcHandler completes the hello exchange using NETCONF 1.1 framing, then answers every rpc with
+// the result of reply, until the client goes away.
+func rpcHandler(sessionID int, reply func(messageID, request string) string) netconfHandler {
+	return func(ch ssh.Channel) {
+		if _, err := ch.Write([]byte(serverHello(sessionID))); err != nil {
+			return
+		}
+		r := bufio.NewReader(ch)
+		if _, err := readEOM(r); err != nil {
+			return
+		}
+		for {
+			request, err := readChunked(r)
+			if err != nil {
+				return
+			}
+			if err := writeChunked(ch, reply(messageID(request), request)); err != nil {
+				return
+			}
+		}
+	}
+}
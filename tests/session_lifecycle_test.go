@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+	"golang.org/x/crypto/ssh"
+)
+
+// hangUpHandler completes the hello exchange, then closes the channel once it received an rpc.
+func hangUpHandler() netconfHandler {
+	return func(ch ssh.Channel) {
+		if _, err := ch.Write([]byte(serverHello(1))); err != nil {
+			return
+		}
+		r := bufio.NewReader(ch)
+		if _, err := readEOM(r); err != nil {
+			return
+		}
+		_, _ = readChunked(r)
+	}
+}
+
+func waitDone(t *testing.T, session *netconf.Session) {
+	select {
+	case <-session.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("session not closed")
+	}
+}
+
+func TestSessionClosedByServer(t *testing.T) {
+	session := newTestSession(t, hangUpHandler())
+	defer session.Close()
+
+	if session.Err() != nil || session.Closed() {
+		t.Fatalf("got %v, wanted an open session", session.Err())
+	}
+
+	start := time.Now()
+	_, err := session.SyncRPCContext(context.Background(), message.NewCommit(), netconf.WithCallTimeout(30*time.Second))
+	if !errors.Is(err, netconf.ErrSessionClosed) || !errors.Is(err, io.EOF) {
+		t.Fatalf("got %v, wanted %v caused by %v", err, netconf.ErrSessionClosed, io.EOF)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("pending rpc failed after %s", elapsed)
+	}
+
+	waitDone(t, session)
+	var closedErr *netconf.SessionClosedError
+	if !errors.As(session.Err(), &closedErr) || closedErr.Cause != io.EOF {
+		t.Errorf("got %#v, wanted a *netconf.SessionClosedError caused by %v", session.Err(), io.EOF)
+	}
+	if !session.Closed() || !session.IsClosed {
+		t.Errorf("session not reported as closed")
+	}
+
+	// Later RPCs fail without waiting for their timeout.
+	if _, err := session.SyncRPC(message.NewCommit(), 30); !errors.Is(err, netconf.ErrSessionClosed) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrSessionClosed)
+	}
+	if err := session.AsyncRPC(message.NewCommit(), func(netconf.Event) {}); !errors.Is(err, netconf.ErrSessionClosed) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrSessionClosed)
+	}
+	if _, err := session.StreamRPC(message.NewGet("", ""), 30); !errors.Is(err, netconf.ErrSessionClosed) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrSessionClosed)
+	}
+}
+
+func TestSessionClosedByClient(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	session := newTestSession(t, heldHandler(release))
+
+	notifications := make(chan netconf.Event, 1)
+	session.Listener.Register(message.NetconfNotificationStreamHandler, func(event netconf.Event) {
+		notifications <- event
+	})
+	replies := make(chan netconf.Event, 1)
+	if err := session.AsyncRPC(message.NewCommit(), func(event netconf.Event) { replies <- event }); err != nil {
+		t.Fatalf("failed to send rpc: %v", err)
+	}
+
+	if err := session.Close(); err != nil {
+		t.Errorf("failed to close session: %v", err)
+	}
+	waitDone(t, session)
+	var closedErr *netconf.SessionClosedError
+	if !errors.As(session.Err(), &closedErr) || closedErr.Cause != nil {
+		t.Errorf("got %#v, wanted a *netconf.SessionClosedError without cause", session.Err())
+	}
+
+	for name, events := range map[string]chan netconf.Event{"rpc-reply": replies, "notification": notifications} {
+		select {
+		case event := <-events:
+			if !errors.Is(event.Err(), netconf.ErrSessionClosed) || event.RPCReply() != nil || event.Notification() != nil {
+				t.Errorf("got %v, wanted a %s termination event", event.Err(), name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("no %s termination event received", name)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	sshUser     = "admin"
+	sshPassword = "admin"
+)
+
+// netconfHandler serves the NETCONF subsystem of an SSH channel.
+type netconfHandler func(ch ssh.Channel)
+
+// helloHandler sends a server hello and waits for the client hello before returning.
+func helloHandler(sessionID int) netconfHandler {
+	return func(ch ssh.Channel) {
+		if _, err := ch.Write([]byte(serverHello(sessionID))); err != nil {
+			return
+		}
+		_, _ = readEOM(bufio.NewReader(ch))
+	}
+}
+
+// newSSHServerConfig returns a server configuration accepting the sshUser/sshPassword credentials,
+// together with the server host key.
+func newSSHServerConfig(t *testing.T) (*ssh.ServerConfig, ssh.Signer) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("failed to create host key signer: %v", err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == sshUser && string(password) == sshPassword {
+				return nil, nil
+			}
+			return nil, errAuthFailed
+		},
+	}
+	config.AddHostKey(signer)
+	return config, signer
+}
+
+// sshClientConfig returns a client configuration matching newSSHServerConfig.
+func sshClientConfig() *ssh.ClientConfig {
+	return &ssh.ClientConfig{
+		User:            sshUser,
+		Auth:            []ssh.AuthMethod{ssh.Password(sshPassword)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+}
+
+// serveSSH runs the SSH server side of conn, starting handler on every channel requesting the netconf subsystem.
+func serveSSH(conn net.Conn, config *ssh.ServerConfig, handler netconfHandler) {
+	serveSSHWith(conn, config, handler, ssh.DiscardRequests)
+}
+
+// serveSSHWith is like serveSSH, handing the global requests to globalRequests.
+func serveSSHWith(conn net.Conn, config *ssh.ServerConfig, handler netconfHandler, globalRequests func(<-chan *ssh.Request)) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	defer sconn.Close()
+	go globalRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		ch, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "netconf"
+				_ = req.Reply(ok, nil)
+				if ok {
+					go func() {
+						defer ch.Close()
+						handler(ch)
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// listenSSH starts an SSH server on a random local port.
+func listenSSH(t *testing.T, config *ssh.ServerConfig, handler netconfHandler) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, config, handler)
+		}
+	}()
+	return listener
+}
+
+// dialSSHPipe connects a TransportSSH to an in-process SSH server running handler.
+func dialSSHPipe(t *testing.T, handler netconfHandler) *netconf.TransportSSH {
+	serverConfig, _ := newSSHServerConfig(t)
+	serverConn, clientConn := socketPair(t)
+	go serveSSH(serverConn, serverConfig, handler)
+
+	c, chans, reqs, err := ssh.NewClientConn(clientConn, "pipe", sshClientConfig())
+	if err != nil {
+		t.Fatalf("failed to establish ssh connection: %v", err)
+	}
+	transport, err := netconf.NoDialSSH(ssh.NewClient(c, chans, reqs))
+	if err != nil {
+		t.Fatalf("failed to create transport: %v", err)
+	}
+	t.Cleanup(func() { _ = transport.Close() })
+	return transport
+}
+
+// rawHandler writes data as-is and closes the channel.
+func rawHandler(data string) netconfHandler {
+	return func(ch ssh.Channel) {
+		_, _ = ch.Write([]byte(data))
+	}
+}
+
+// socketPair returns both ends of a loopback TCP connection. Unlike net.Pipe, writes are buffered,
+// which the SSH version exchange relies on.
+func socketPair(t *testing.T) (net.Conn, net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := listener.Accept()
+		accepted <- conn
+	}()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	server := <-accepted
+	if server == nil {
+		t.Fatalf("failed to accept connection")
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return server, client
+}
+
+// newTestSession establishes a NETCONF session with an in-process server running handler.
+func newTestSession(t *testing.T, handler netconfHandler, options ...netconf.SessionOption) *netconf.Session {
+	session, err := netconf.NewSession(dialSSHPipe(t, handler), options...)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	return session
+}
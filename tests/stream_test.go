@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+)
+
+const interfaceCount = 10000
+
+// interfacesReply replies to `get` with a large list of interfaces, and to anything else with <ok/>.
+func interfacesReply(messageID, request string) string {
+	if strings.Contains(request, "<get-config>") {
+		return fmt.Sprintf(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%s"><rpc-error><error-type>application</error-type><error-tag>operation-not-supported</error-tag><error-severity>error</error-severity><error-message>not supported</error-message></rpc-error></rpc-reply>`, messageID)
+	}
+	if !strings.Contains(request, "<get>") {
+		return okReply(messageID, request)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%s"><data><interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">`, messageID)
+	for i := 0; i < interfaceCount; i++ {
+		fmt.Fprintf(&b, "<interface><name>eth%d</name><enabled>true</enabled></interface>", i)
+	}
+	b.WriteString("</interfaces></data></rpc-reply>")
+	return b.String()
+}
+
+type iface struct {
+	Name    string `xml:"name"`
+	Enabled bool   `xml:"enabled"`
+}
+
+func TestStreamRPC(t *testing.T) {
+	session := newTestSession(t, rpcHandler(1, interfacesReply))
+	defer session.Close()
+
+	reply, err := session.StreamRPC(message.NewGet("", ""), 5)
+	if err != nil {
+		t.Fatalf("failed to stream rpc: %v", err)
+	}
+
+	count := 0
+	err = netconf.ForEachElement(reply, "interfaces/interface", func(d *xml.Decoder, start xml.StartElement) error {
+		var i iface
+		if err := d.DecodeElement(&i, &start); err != nil {
+			return err
+		}
+		if want := fmt.Sprintf("eth%d", count); i.Name != want || !i.Enabled {
+			return fmt.Errorf("got %+v, wanted %s", i, want)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to decode reply: %v", err)
+	}
+	if count != interfaceCount {
+		t.Errorf("got %d interfaces, wanted %d", count, interfaceCount)
+	}
+
+	// The session keeps processing messages once the reply is consumed.
+	if _, err := session.SyncRPC(message.NewCommit(), 5); err != nil {
+		t.Errorf("failed to execute rpc after streaming: %v", err)
+	}
+}
+
+func TestStreamRPCClose(t *testing.T) {
+	session := newTestSession(t, rpcHandler(1, interfacesReply))
+	defer session.Close()
+
+	reply, err := session.StreamRPC(message.NewGet("", ""), 5)
+	if err != nil {
+		t.Fatalf("failed to stream rpc: %v", err)
+	}
+	head := make([]byte, 16)
+	if _, err := io.ReadFull(reply, head); err != nil {
+		t.Fatalf("failed to read reply: %v", err)
+	}
+	if string(head) != "<rpc-reply xmlns" {
+		t.Errorf("got %q, wanted the beginning of the rpc-reply", head)
+	}
+	// Closing before the end discards the remainder of the reply.
+	if err := reply.Close(); err != nil {
+		t.Fatalf("failed to close reply: %v", err)
+	}
+
+	if _, err := session.SyncRPC(message.NewCommit(), 5); err != nil {
+		t.Errorf("failed to execute rpc after closing the stream: %v", err)
+	}
+}
+
+func TestStreamRPCAbandoned(t *testing.T) {
+	var logs syncBuffer
+	session := newTestSession(t, rpcHandler(1, interfacesReply), netconf.WithSessionLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+
+	reply, err := session.StreamRPC(message.NewGet("", ""), 5)
+	if err != nil {
+		t.Fatalf("failed to stream rpc: %v", err)
+	}
+
+	// The reply is never read: closing the session must still release the goroutine reading the messages.
+	_ = session.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(logs.String(), "exit receiving loop") {
+		if time.Now().After(deadline) {
+			t.Fatalf("reader not released by closing the session")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_ = reply.Close()
+}
+
+func TestStreamRPCError(t *testing.T) {
+	session := newTestSession(t, rpcHandler(1, interfacesReply))
+	defer session.Close()
+
+	reply, err := session.StreamRPC(message.NewGetConfig(message.DatastoreRunning, "", ""), 5)
+	if err != nil {
+		t.Fatalf("failed to stream rpc: %v", err)
+	}
+	defer reply.Close()
+
+	err = netconf.ForEachElement(reply, "interfaces/interface", func(d *xml.Decoder, start xml.StartElement) error {
+		return d.Skip()
+	})
+	var rpcErr *message.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Tag != "operation-not-supported" {
+		t.Errorf("got %v, wanted rpc-error operation-not-supported", err)
+	}
+}
+
+func TestStreamRPCContextDeadline(t *testing.T) {
+	release := make(chan struct{})
+	session := newTestSession(t, heldHandler(release))
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := session.StreamRPCContext(ctx, message.NewGet("", "")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, wanted %v", err, context.DeadlineExceeded)
+	}
+	// A context already done does not send anything.
+	if _, err := session.StreamRPCContext(ctx, message.NewGet("", "")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, wanted %v", err, context.DeadlineExceeded)
+	}
+
+	// The late reply is discarded, and the session is still usable.
+	close(release)
+	reply, err := session.StreamRPCContext(context.Background(), message.NewGet("", ""), netconf.WithCallTimeout(5*time.Second))
+	if err != nil {
+		t.Fatalf("failed to stream rpc: %v", err)
+	}
+	defer reply.Close()
+	if b, err := io.ReadAll(reply); err != nil || !strings.Contains(string(b), "<ok/>") {
+		t.Errorf("got %q, %v, wanted an ok reply", b, err)
+	}
+}
@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+)
+
+func readTrace(t *testing.T, trace []byte) []*netconf.TraceEntry {
+	var entries []*netconf.TraceEntry
+	d := netconf.NewTraceDecoder(bytes.NewReader(trace))
+	for {
+		entry, err := d.Decode()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("failed to decode trace: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var trace bytes.Buffer
+	session := newTestSession(t, rpcHandler(1, okReply), netconf.WithTrace(&trace, netconf.DefaultRedactionRules...))
+
+	editConfig := message.NewEditConfig(message.DatastoreRunning, message.DefaultOperationTypeMerge,
+		`<users><user><name>admin</name><nc:password xmlns:nc="urn:example">s3cr3t</nc:password></user></users>`)
+	reply, err := session.SyncRPC(editConfig, 5)
+	if err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	if len(reply.Errors) != 0 {
+		t.Errorf("got errors %v", reply.Errors)
+	}
+	stream, err := session.StreamRPC(message.NewGet("", ""), 5)
+	if err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	if _, err := io.Copy(io.Discard, stream); err != nil {
+		t.Fatalf("failed to read reply: %v", err)
+	}
+	_ = stream.Close()
+	_ = session.Close()
+
+	entries := readTrace(t, trace.Bytes())
+	if len(entries) != 6 {
+		t.Fatalf("got %d entries, wanted 6", len(entries))
+	}
+
+	// The hello messages use the 1.0 framing, whatever the order they were exchanged in.
+	for _, entry := range entries[:2] {
+		if !strings.Contains(entry.Data, "<hello") || entry.Version != "v1.0" || entry.MessageID != "" {
+			t.Errorf("got %+v, wanted a hello message", entry)
+		}
+	}
+
+	want := []struct {
+		direction netconf.TraceDirection
+		messageID string
+	}{
+		{netconf.TraceSent, editConfig.GetMessageID()},
+		{netconf.TraceReceived, editConfig.GetMessageID()},
+		{netconf.TraceSent, ""},
+		{netconf.TraceReceived, ""},
+	}
+	for i, w := range want {
+		entry := entries[i+2]
+		if entry.Direction != w.direction || entry.Version != "v1.1" || entry.Time.IsZero() {
+			t.Errorf("got %+v, wanted a %s message", entry, w.direction)
+		}
+		if w.messageID != "" && entry.MessageID != w.messageID {
+			t.Errorf("got message-id %q, wanted %q", entry.MessageID, w.messageID)
+		}
+	}
+	if entries[4].MessageID == "" || entries[4].MessageID != entries[5].MessageID {
+		t.Errorf("got message-ids %q and %q, wanted the streamed rpc and its reply", entries[4].MessageID, entries[5].MessageID)
+	}
+
+	if strings.Contains(trace.String(), "s3cr3t") {
+		t.Errorf("password recorded in trace")
+	}
+	if !strings.Contains(entries[2].Data, `<nc:password xmlns:nc="urn:example">*****</nc:password>`) {
+		t.Errorf("got %s, wanted the password to be redacted", entries[2].Data)
+	}
+}
+
+func TestTraceRedactRegexp(t *testing.T) {
+	var trace bytes.Buffer
+	session := newTestSession(t, rpcHandler(1, okReply), netconf.WithTrace(&trace,
+		netconf.RedactRegexp(regexp.MustCompile(`key="[^"]*"`), `key="-"`),
+	))
+	defer session.Close()
+
+	if _, err := session.SyncRPC(message.NewRPC(`<get-key key="abcd"/>`), 5); err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	if strings.Contains(trace.String(), "abcd") || !strings.Contains(trace.String(), `key=\"-\"`) {
+		t.Errorf("got %s, wanted the key to be redacted", trace.String())
+	}
+}
+
+// failingTransport fails to send the messages while fail is set.
+type failingTransport struct {
+	*netconf.TransportSSH
+	fail atomic.Bool
+}
+
+var errSendFailed = errors.New("send failed")
+
+func (t *failingTransport) Send(b []byte) error {
+	if t.fail.Load() {
+		return errSendFailed
+	}
+	return t.TransportSSH.Send(b)
+}
+
+func TestTraceSendError(t *testing.T) {
+	var trace bytes.Buffer
+	transport := &failingTransport{TransportSSH: dialSSHPipe(t, rpcHandler(1, okReply))}
+	session, err := netconf.NewSession(transport, netconf.WithTrace(&trace))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	transport.fail.Store(true)
+	failed := message.NewCommit()
+	if _, err := session.SyncRPC(failed, 5); !errors.Is(err, errSendFailed) {
+		t.Errorf("got %v, wanted %v", err, errSendFailed)
+	}
+	transport.fail.Store(false)
+	commit(t, session)
+	_ = session.Close()
+
+	entries := readTrace(t, trace.Bytes())
+	if len(entries) != 6 {
+		t.Fatalf("got %d entries, wanted 6", len(entries))
+	}
+	// The request is followed by the error met sending it.
+	if entry := entries[2]; entry.MessageID != failed.GetMessageID() || entry.Err != "" {
+		t.Errorf("got %+v, wanted the failed request", entry)
+	}
+	entry := entries[3]
+	if entry.Direction != netconf.TraceSent || entry.MessageID != failed.GetMessageID() || entry.Err != errSendFailed.Error() || entry.Data != "" {
+		t.Errorf("got %+v, wanted the send error", entry)
+	}
+	for _, entry := range entries[4:] {
+		if entry.Err != "" {
+			t.Errorf("got error %q for the successful rpc", entry.Err)
+		}
+	}
+
+	var transcript bytes.Buffer
+	if err := netconf.WriteTranscript(&transcript, bytes.NewReader(trace.Bytes())); err != nil {
+		t.Fatalf("failed to write transcript: %v", err)
+	}
+	if !strings.Contains(transcript.String(), "message-id="+failed.GetMessageID()+"\nfailed to send: send failed\n") {
+		t.Errorf("got\n%s\nwanted the send error", transcript.String())
+	}
+}
+
+func TestWriteTranscript(t *testing.T) {
+	trace := `{"time":"2024-05-01T10:00:00Z","direction":"sent","version":"v1.1","message-id":"42","data":"<rpc message-id=\"42\"><commit/></rpc>"}
+{"time":"2024-05-01T10:00:01.5Z","direction":"received","version":"v1.1","message-id":"42","data":"<rpc-reply message-id=\"42\"><ok/></rpc-reply>\n"}
+`
+	var transcript bytes.Buffer
+	if err := netconf.WriteTranscript(&transcript, strings.NewReader(trace)); err != nil {
+		t.Fatalf("failed to write transcript: %v", err)
+	}
+	want := `2024-05-01T10:00:00.000Z >>> sent v1.1 message-id=42
+<rpc message-id="42"><commit/></rpc>
+
+2024-05-01T10:00:01.500Z <<< received v1.1 message-id=42
+<rpc-reply message-id="42"><ok/></rpc-reply>
+
+`
+	if transcript.String() != want {
+		t.Errorf("got\n%s\nwanted\n%s", transcript.String(), want)
+	}
+}
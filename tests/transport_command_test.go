@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+)
+
+const helperModeEnv = "NETCONF_HELPER_MODE"
+
+// TestHelperProcess is not a real test: it is started by the command transport tests, and acts
+// as a NETCONF server over its stdin and stdout.
+func TestHelperProcess(t *testing.T) {
+	switch os.Getenv(helperModeEnv) {
+	case "":
+		return
+	case "serve":
+		fmt.Fprintln(os.Stderr, "helper started")
+		_, _ = os.Stdout.WriteString(serverHello(7))
+		r := bufio.NewReader(os.Stdin)
+		if _, err := readEOM(r); err != nil {
+			os.Exit(2)
+		}
+		for {
+			request, err := readChunked(r)
+			if err != nil {
+				// The client closed stdin.
+				os.Exit(0)
+			}
+			if err := writeChunked(os.Stdout, okReply(messageID(request), request)); err != nil {
+				os.Exit(2)
+			}
+		}
+	case "fail":
+		fmt.Fprintln(os.Stderr, "permission denied")
+		os.Exit(3)
+	}
+}
+
+// helperCommand returns the arguments starting the test binary as a helper process running mode.
+func helperCommand(t *testing.T, mode string) (string, []string) {
+	t.Setenv(helperModeEnv, mode)
+	return os.Args[0], []string{"-test.run=^TestHelperProcess$"}
+}
+
+func TestCommandTransport(t *testing.T) {
+	name, args := helperCommand(t, "serve")
+	transport, err := netconf.DialCommand(name, args...)
+	if err != nil {
+		t.Fatalf("failed to start command: %v", err)
+	}
+
+	session, err := netconf.NewSession(transport)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if session.SessionID != 7 {
+		t.Errorf("got session-id %d, wanted 7", session.SessionID)
+	}
+
+	reply, err := session.SyncRPC(message.NewCommit(), 5)
+	if err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	if len(reply.Errors) != 0 {
+		t.Errorf("got errors %v", reply.Errors)
+	}
+
+	if err := session.Close(); err != nil {
+		t.Errorf("got %v when closing, wanted a clean exit", err)
+	}
+	if !strings.Contains(transport.Stderr(), "helper started") {
+		t.Errorf("got stderr %q", transport.Stderr())
+	}
+}
+
+func TestCommandTransportExitStatus(t *testing.T) {
+	name, args := helperCommand(t, "fail")
+	transport, err := netconf.DialCommand(name, args...)
+	if err != nil {
+		t.Fatalf("failed to start command: %v", err)
+	}
+
+	_, err = netconf.NewSession(transport)
+	var exitErr *netconf.CommandExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("got %v, wanted a *netconf.CommandExitError", err)
+	}
+	if exitErr.ExitCode() != 3 {
+		t.Errorf("got exit code %d, wanted 3", exitErr.ExitCode())
+	}
+	if !strings.Contains(exitErr.Error(), "permission denied") {
+		t.Errorf("got %q, wanted the stderr of the command", exitErr.Error())
+	}
+	if !errors.As(transport.Err(), &exitErr) {
+		t.Errorf("got %v, wanted the exit status", transport.Err())
+	}
+}
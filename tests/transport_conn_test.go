@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+	"golang.org/x/crypto/ssh"
+)
+
+// connChannel exposes a plain connection as an ssh.Channel, so the NETCONF handlers can be reused.
+type connChannel struct {
+	net.Conn
+}
+
+func (c connChannel) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Close()
+}
+
+func (c connChannel) SendRequest(string, bool, []byte) (bool, error) {
+	return false, nil
+}
+
+func (c connChannel) Stderr() io.ReadWriter {
+	return nil
+}
+
+// listenConn serves handler on every connection accepted on a new listener of the provided network.
+func listenConn(t *testing.T, network string, address string, handler netconfHandler) net.Listener {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handler(connChannel{conn})
+			}()
+		}
+	}()
+	return listener
+}
+
+func TestSessionFromTCP(t *testing.T) {
+	listener := listenConn(t, "tcp", "127.0.0.1:0", rpcHandler(3, okReply))
+
+	session, err := netconf.NewSessionFromTCP(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	defer session.Close()
+
+	if session.SessionID != 3 {
+		t.Errorf("got session-id %d, wanted 3", session.SessionID)
+	}
+	reply, err := session.SyncRPC(message.NewCommit(), 5)
+	if err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+	if len(reply.Errors) != 0 {
+		t.Errorf("got errors %v", reply.Errors)
+	}
+}
+
+func TestSessionFromUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netconf.sock")
+	listenConn(t, "unix", path, rpcHandler(4, okReply))
+
+	session, err := netconf.NewSessionFromUnix(path)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	defer session.Close()
+
+	if session.SessionID != 4 {
+		t.Errorf("got session-id %d, wanted 4", session.SessionID)
+	}
+	if _, err := session.SyncRPC(message.NewCommit(), 5); err != nil {
+		t.Fatalf("failed to execute rpc: %v", err)
+	}
+}
+
+func TestDialTCPRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+
+	if _, err := netconf.DialTCP(address); err == nil {
+		t.Fatalf("dialed a closed port")
+	}
+}
+
+var _ ssh.Channel = connChannel{}
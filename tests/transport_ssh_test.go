@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+)
+
+// listenSSHTracked is like listenSSH, and reports on the returned channel every SSH connection closed.
+func listenSSHTracked(t *testing.T, handler netconfHandler) (string, <-chan struct{}) {
+	config, _ := newSSHServerConfig(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	closed := make(chan struct{}, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				serveSSH(conn, config, handler)
+				closed <- struct{}{}
+			}()
+		}
+	}()
+	return listener.Addr().String(), closed
+}
+
+func TestSSHOpenChannel(t *testing.T) {
+	address, closed := listenSSHTracked(t, rpcHandler(1, okReply))
+
+	transport, err := netconf.DialSSH(address, sshClientConfig())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	config, err := netconf.NewSession(transport)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	notifications, err := netconf.NewSessionFromSSHTransport(transport)
+	if err != nil {
+		t.Fatalf("failed to create second session: %v", err)
+	}
+	commit(t, config)
+	commit(t, notifications)
+
+	// Closing the first session keeps the connection open for the second one.
+	_ = config.Close()
+	commit(t, notifications)
+	select {
+	case <-closed:
+		t.Fatalf("connection closed while still in use")
+	default:
+	}
+
+	_ = notifications.Close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("connection not closed along with the last session")
+	}
+
+	if _, err := transport.OpenChannel(); !errors.Is(err, netconf.ErrSSHConnectionClosed) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrSSHConnectionClosed)
+	}
+}
+
+func TestSSHCloseTwice(t *testing.T) {
+	address, closed := listenSSHTracked(t, rpcHandler(1, okReply))
+
+	transport, err := netconf.DialSSH(address, sshClientConfig())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	channel, err := transport.OpenChannel()
+	if err != nil {
+		t.Fatalf("failed to open channel: %v", err)
+	}
+
+	// Closing a transport twice must not release the connection of the other one.
+	_ = transport.Close()
+	_ = transport.Close()
+	select {
+	case <-closed:
+		t.Fatalf("connection closed while still in use")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	_ = channel.Close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("connection not closed along with the last transport")
+	}
+}
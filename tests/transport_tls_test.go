@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+)
+
+// startTLSServer starts a NETCONF over TLS server requiring a client certificate issued by pki.
+// The server sends its hello and then waits for the client hello.
+func startTLSServer(t *testing.T, pki *testPKI, serverCert tls.Certificate) net.Listener {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pki.pool,
+	})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if _, err := conn.Write([]byte(serverHello(4))); err != nil {
+					return
+				}
+				_, _ = readEOM(bufio.NewReader(conn))
+			}()
+		}
+	}()
+	return listener
+}
+
+func TestTLSSession(t *testing.T) {
+	pki := newTestPKI(t)
+	listener := startTLSServer(t, pki, pki.issue("device", x509.ExtKeyUsageServerAuth, "device.example.com"))
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{pki.issue("admin", x509.ExtKeyUsageClientAuth)},
+		RootCAs:      pki.pool,
+	}
+	session, err := netconf.NewSessionFromTLSConfig(listener.Addr().String(), config)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	defer session.Close()
+
+	if session.SessionID != 4 {
+		t.Errorf("got session-id %d, wanted 4", session.SessionID)
+	}
+	if len(session.Capabilities) != 2 {
+		t.Errorf("got capabilities %v, wanted 2 capabilities", session.Capabilities)
+	}
+}
+
+func TestTLSCertToName(t *testing.T) {
+	pki := newTestPKI(t)
+	serverCert := pki.issue("device", x509.ExtKeyUsageServerAuth, "device.example.com")
+	listener := startTLSServer(t, pki, serverCert)
+	clientCert := pki.issue("admin", x509.ExtKeyUsageClientAuth)
+
+	tests := []struct {
+		name         string
+		expectations []netconf.CertToName
+		wantErr      bool
+	}{
+		{"fingerprint", []netconf.CertToName{{Fingerprint: netconf.CertificateFingerprint(serverCert.Leaf)}}, false},
+		{"san", []netconf.CertToName{{Name: "device.example.com"}}, false},
+		{"common-name", []netconf.CertToName{{Name: "device"}}, false},
+		{"fingerprint and name", []netconf.CertToName{{Fingerprint: netconf.CertificateFingerprint(serverCert.Leaf), Name: "other"}}, true},
+		{"second expectation", []netconf.CertToName{{Name: "other"}, {Name: "device.example.com"}}, false},
+		{"mismatch", []netconf.CertToName{{Fingerprint: netconf.CertificateFingerprint(clientCert.Leaf)}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &tls.Config{
+				Certificates:          []tls.Certificate{clientCert},
+				RootCAs:               pki.pool,
+				VerifyPeerCertificate: netconf.VerifyCertToName(tt.expectations...),
+			}
+			transport, err := netconf.DialTLS(listener.Addr().String(), config)
+			if tt.wantErr {
+				if !errors.Is(err, netconf.ErrCertToName) {
+					t.Errorf("got error %v, wanted %v", err, netconf.ErrCertToName)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to dial: %v", err)
+			}
+			_ = transport.Close()
+		})
+	}
+}
+
+func TestTLSUntrustedServer(t *testing.T) {
+	pki := newTestPKI(t)
+	listener := startTLSServer(t, pki, pki.issue("device", x509.ExtKeyUsageServerAuth))
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{pki.issue("admin", x509.ExtKeyUsageClientAuth)},
+		RootCAs:      newTestPKI(t).pool,
+	}
+	if _, err := netconf.NewSessionFromTLSConfig(listener.Addr().String(), config); err == nil {
+		t.Errorf("expected session creation to fail against an untrusted server")
+	}
+}
+
+func TestTLSMissingClientCertificate(t *testing.T) {
+	pki := newTestPKI(t)
+	listener := startTLSServer(t, pki, pki.issue("device", x509.ExtKeyUsageServerAuth))
+
+	config := &tls.Config{RootCAs: pki.pool}
+	if _, err := netconf.NewSessionFromTLSConfig(listener.Addr().String(), config); err == nil {
+		t.Errorf("expected session creation to fail without a client certificate")
+	}
+}
@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+)
+
+// waitStats polls the statistics of the session until cond holds.
+func waitStats(t *testing.T, session *netconf.Session, cond func(netconf.Stats) bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond(session.Stats()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %+v", session.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWindowQueue(t *testing.T) {
+	release := make(chan struct{})
+	session := newTestSession(t, heldHandler(release), netconf.WithMaxInFlight(2))
+	defer session.Close()
+
+	replies := make(chan netconf.Event, 3)
+	for i := 0; i < 2; i++ {
+		if err := session.AsyncRPC(message.NewCommit(), func(e netconf.Event) { replies <- e }); err != nil {
+			t.Fatalf("failed to send rpc: %v", err)
+		}
+	}
+	if stats := session.Stats(); stats.InFlight != 2 || stats.MaxInFlight != 2 || stats.Sent != 2 {
+		t.Errorf("got %+v, wanted 2 rpcs in flight", stats)
+	}
+
+	// Queued RPCs are bounded by their context.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := session.SyncRPCContext(ctx, message.NewCommit()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, wanted %v", err, context.DeadlineExceeded)
+	}
+
+	// A queued RPC is sent once a reply releases a slot.
+	queued := make(chan error, 1)
+	go func() {
+		_, err := session.SyncRPC(message.NewCommit(), 5)
+		queued <- err
+	}()
+	waitStats(t, session, func(s netconf.Stats) bool { return s.Queued == 1 })
+	close(release)
+	if err := <-queued; err != nil {
+		t.Errorf("failed to execute queued rpc: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if e := <-replies; e.Err() != nil {
+			t.Errorf("got %v", e.Err())
+		}
+	}
+	waitStats(t, session, func(s netconf.Stats) bool { return s.InFlight == 0 && s.Queued == 0 && s.Sent == 3 })
+}
+
+func TestWindowFailFast(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	session := newTestSession(t, heldHandler(release), netconf.WithMaxInFlight(1), netconf.WithWindowFailFast())
+	defer session.Close()
+
+	if err := session.AsyncRPC(message.NewCommit(), func(netconf.Event) {}); err != nil {
+		t.Fatalf("failed to send rpc: %v", err)
+	}
+	if err := session.AsyncRPC(message.NewCommit(), func(netconf.Event) {}); !errors.Is(err, netconf.ErrWindowFull) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrWindowFull)
+	}
+	if _, err := session.SyncRPC(message.NewCommit(), 5); !errors.Is(err, netconf.ErrWindowFull) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrWindowFull)
+	}
+	if stats := session.Stats(); stats.InFlight != 1 || stats.Rejected != 2 || stats.Queued != 0 {
+		t.Errorf("got %+v, wanted 2 rejected rpcs", stats)
+	}
+}
+
+func TestWindowReleasedOnClose(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	session := newTestSession(t, heldHandler(release), netconf.WithMaxInFlight(1))
+
+	if err := session.AsyncRPC(message.NewCommit(), func(netconf.Event) {}); err != nil {
+		t.Fatalf("failed to send rpc: %v", err)
+	}
+	queued := make(chan error, 1)
+	go func() {
+		_, err := session.SyncRPC(message.NewCommit(), 30)
+		queued <- err
+	}()
+	waitStats(t, session, func(s netconf.Stats) bool { return s.Queued == 1 })
+
+	_ = session.Close()
+	if err := <-queued; !errors.Is(err, netconf.ErrSessionClosed) {
+		t.Errorf("got %v, wanted %v", err, netconf.ErrSessionClosed)
+	}
+	waitStats(t, session, func(s netconf.Stats) bool { return s.InFlight == 0 && s.Queued == 0 })
+}
+
+func TestWindowPipelining(t *testing.T) {
+	const window, rpcs = 8, 2000
+	session, _ := newMemorySession(t, netconf.WithMaxInFlight(window))
+
+	var wg sync.WaitGroup
+	maxInFlight := 0
+	for i := 0; i < rpcs; i++ {
+		wg.Add(1)
+		err := session.AsyncRPC(message.NewCommit(), func(e netconf.Event) {
+			defer wg.Done()
+			if e.Err() != nil {
+				t.Errorf("got %v", e.Err())
+			}
+		})
+		if err != nil {
+			t.Fatalf("failed to send rpc: %v", err)
+		}
+		maxInFlight = max(maxInFlight, session.Stats().InFlight)
+	}
+	wg.Wait()
+
+	if maxInFlight > window {
+		t.Errorf("got %d rpcs in flight, wanted at most %d", maxInFlight, window)
+	}
+	if stats := session.Stats(); stats.Sent != rpcs {
+		t.Errorf("got %d rpcs sent, wanted %d", stats.Sent, rpcs)
+	}
+}